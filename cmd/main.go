@@ -7,6 +7,7 @@ import (
 	"github.com/hatlonely/go-kit/flag"
 	"github.com/hatlonely/go-kit/refx"
 	"github.com/hatlonely/go-kit/strx"
+	"github.com/pkg/errors"
 
 	"github.com/hatlonely/benv2/internal/framework"
 )
@@ -28,6 +29,7 @@ const (
 	ECFrameworkNewFailed     = 3
 	ECFrameworkRunFailed     = 4
 	ECFrameworkAnalystFailed = 5
+	ECHealthCheckFailed      = 6
 )
 
 func main() {
@@ -77,6 +79,9 @@ func main() {
 	if options.Action == "run" {
 		if err := fw.Run(); err != nil {
 			strx.Warn(err.Error())
+			if _, ok := errors.Cause(err).(*framework.HealthCheckError); ok {
+				os.Exit(ECHealthCheckFailed)
+			}
 			os.Exit(ECFrameworkRunFailed)
 		}
 	} else if options.Action == "analyst" {
@@ -1,6 +1,6 @@
 module github.com/hatlonely/benv2
 
-go 1.16
+go 1.19
 
 require (
 	github.com/PaesslerAG/gval v1.1.0
@@ -10,10 +10,17 @@ require (
 	github.com/alibabacloud-go/tea-utils/v2 v2.0.0
 	github.com/barkimedes/go-deepcopy v0.0.0-20220514131651-17c30cfc62df
 	github.com/generikvault/gvalstrings v0.0.0-20180926130504-471f38f0112a
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/gorilla/websocket v1.5.0
 	github.com/hatlonely/go-kit v1.1.6
+	github.com/jhump/protoreflect v1.15.3
 	github.com/json-iterator/go v1.1.10
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/pkg/errors v0.9.1
 	github.com/satori/go.uuid v1.2.0
 	github.com/smartystreets/goconvey v1.7.2
 	github.com/spf13/cast v1.3.1
+	google.golang.org/grpc v1.57.0
+	google.golang.org/protobuf v1.31.0
 )
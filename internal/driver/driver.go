@@ -3,6 +3,7 @@ package driver
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"reflect"
 	"strconv"
 	"unsafe"
@@ -55,6 +56,20 @@ type Driver interface {
 	Do(req interface{}) (interface{}, error)
 }
 
+// ContextDriver 可以通过 ctx 取消正在执行的请求
+type ContextDriver interface {
+	Driver
+	DoContext(ctx context.Context, req interface{}) (interface{}, error)
+}
+
+// Close 释放驱动持有的连接，子进程等资源，驱动没有实现 io.Closer 时直接返回
+func Close(d Driver) error {
+	if closer, ok := d.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 type WrapDriver struct {
 	//inner     interface{}
 	inner      reflect.Value
@@ -115,6 +130,10 @@ func NewWrapDriverWithMethodName(v interface{}, methodName string) func(options
 }
 
 func (d *WrapDriver) Do(v interface{}) (interface{}, error) {
+	return d.DoContext(context.Background(), v)
+}
+
+func (d *WrapDriver) DoContext(ctx context.Context, v interface{}) (interface{}, error) {
 	methodName := d.methodName
 	if methodName == "" {
 		methodNameV, err := refx.InterfaceGet(v, d.methodKey)
@@ -140,7 +159,7 @@ func (d *WrapDriver) Do(v interface{}) (interface{}, error) {
 	if mt.NumIn() == 1 {
 		// func(ctx)
 		if mt.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem() {
-			return resultToInterface(mt, method.Call([]reflect.Value{reflect.ValueOf(context.Background())}))
+			return resultToInterface(mt, method.Call([]reflect.Value{reflect.ValueOf(ctx)}))
 		}
 
 		// func(req)
@@ -159,7 +178,7 @@ func (d *WrapDriver) Do(v interface{}) (interface{}, error) {
 			if err != nil {
 				return nil, NewErrorf(err, "driver.ConstructReqFailed", "refx.InterfaceToStruct failed, err: [%s]", err.Error())
 			}
-			return resultToInterface(mt, method.Call([]reflect.Value{reflect.ValueOf(context.Background()), req.Elem()}))
+			return resultToInterface(mt, method.Call([]reflect.Value{reflect.ValueOf(ctx), req.Elem()}))
 		}
 	}
 
@@ -167,7 +186,7 @@ func (d *WrapDriver) Do(v interface{}) (interface{}, error) {
 	idx := 0
 	// func(ctx, arg1, arg2, ...)
 	if mt.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem() {
-		args = append(args, reflect.ValueOf(context.Background()))
+		args = append(args, reflect.ValueOf(ctx))
 		idx++
 	}
 	// func(arg1, arg2, ...)
@@ -192,6 +211,14 @@ func (d *WrapDriver) Do(v interface{}) (interface{}, error) {
 	return resultToInterface(mt, method.Call(args))
 }
 
+// Close 调用被包装的对象的 Close 方法
+func (d *WrapDriver) Close() error {
+	if closer, ok := d.inner.Interface().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 func resultToInterface(mt reflect.Type, results []reflect.Value) (interface{}, error) {
 	if mt.NumOut() == 0 {
 		return map[string]interface{}{}, nil
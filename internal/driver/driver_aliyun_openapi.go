@@ -0,0 +1,202 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
+	openapiutil "github.com/alibabacloud-go/openapi-util/service"
+	util "github.com/alibabacloud-go/tea-utils/v2/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+type AliyunOpenAPIDriverOptions struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string
+	RegionId        string
+	// 服务的地址，如 ecs.cn-shanghai.aliyuncs.com
+	Endpoint string
+	// HTTPS 或者 HTTP
+	Protocol string `dft:"HTTPS"`
+	// v2 为 RPC 和 ROA 风格各自的签名，ACS3-HMAC-SHA256 为 V3 签名
+	SignatureAlgorithm string        `dft:"v2"`
+	ConnectTimeout     time.Duration `dft:"5s"`
+	ReadTimeout        time.Duration `dft:"10s"`
+	MaxIdleConns       int           `dft:"100"`
+}
+
+func NewAliyunOpenAPIDriverWithOptions(options *AliyunOpenAPIDriverOptions) (*AliyunOpenAPIDriver, error) {
+	if options.Endpoint == "" {
+		return nil, errors.New("Endpoint is required")
+	}
+	if options.Protocol == "" {
+		options.Protocol = "HTTPS"
+	}
+	if options.SignatureAlgorithm == "" {
+		options.SignatureAlgorithm = "v2"
+	}
+
+	config := &openapi.Config{
+		AccessKeyId:        tea.String(options.AccessKeyId),
+		AccessKeySecret:    tea.String(options.AccessKeySecret),
+		Endpoint:           tea.String(options.Endpoint),
+		Protocol:           tea.String(options.Protocol),
+		SignatureAlgorithm: tea.String(options.SignatureAlgorithm),
+		ConnectTimeout:     tea.Int(int(options.ConnectTimeout.Milliseconds())),
+		ReadTimeout:        tea.Int(int(options.ReadTimeout.Milliseconds())),
+		MaxIdleConns:       tea.Int(options.MaxIdleConns),
+	}
+	if options.SecurityToken != "" {
+		config.SecurityToken = tea.String(options.SecurityToken)
+	}
+	if options.RegionId != "" {
+		config.RegionId = tea.String(options.RegionId)
+	}
+
+	client, err := openapi.NewClient(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "openapi.NewClient failed")
+	}
+
+	return &AliyunOpenAPIDriver{
+		options: options,
+		client:  client,
+	}, nil
+}
+
+// AliyunOpenAPIDriver 通过阿里云 OpenAPI 的 SDK 调用 RPC 和 ROA 风格的接口，SDK 负责签名和错误解析
+type AliyunOpenAPIDriver struct {
+	options *AliyunOpenAPIDriverOptions
+	client  *openapi.Client
+}
+
+type AliyunOpenAPIDoReq struct {
+	Action  string
+	Version string
+	// RPC 或者 ROA
+	Style  string
+	Method string
+	// ROA 接口的路径，RPC 接口为 /
+	Pathname string
+	Query    map[string]interface{}
+	Headers  map[string]string
+	Body     interface{}
+	// formData 或者 json，RPC 接口默认 formData，ROA 接口默认 json
+	ReqBodyType string
+	// json，array，string，none，默认 json
+	BodyType string
+	Timeout  time.Duration
+}
+
+type AliyunOpenAPIDoRes struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       interface{}
+}
+
+// Do SDK 不支持 ctx，根据 ctx 的 deadline 和 req.Timeout 设置连接和读超时
+func (d *AliyunOpenAPIDriver) Do(ctx context.Context, req *AliyunOpenAPIDoReq) (*AliyunOpenAPIDoRes, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "ctx done")
+	}
+
+	params := &openapi.Params{
+		Action:      tea.String(req.Action),
+		Version:     tea.String(req.Version),
+		Protocol:    tea.String(d.options.Protocol),
+		Method:      tea.String(req.Method),
+		AuthType:    tea.String("AK"),
+		Style:       tea.String(req.Style),
+		Pathname:    tea.String(req.Pathname),
+		ReqBodyType: tea.String(req.ReqBodyType),
+		BodyType:    tea.String(req.BodyType),
+	}
+	switch strings.ToUpper(req.Style) {
+	case "", "RPC":
+		params.Style = tea.String("RPC")
+		params.Pathname = tea.String("/")
+		if req.ReqBodyType == "" {
+			params.ReqBodyType = tea.String("formData")
+		}
+	case "ROA":
+		params.Style = tea.String("ROA")
+		if req.Pathname == "" {
+			params.Pathname = tea.String("/")
+		}
+		if req.ReqBodyType == "" {
+			params.ReqBodyType = tea.String("json")
+		}
+	default:
+		return nil, NewErrorf(nil, "aliyun.InvalidRequest", "unknown Style [%s]", req.Style)
+	}
+	if req.Method == "" {
+		params.Method = tea.String("POST")
+	}
+	if req.BodyType == "" {
+		params.BodyType = tea.String("json")
+	}
+
+	request := &openapi.OpenApiRequest{Body: req.Body}
+	if req.Query != nil {
+		request.Query = openapiutil.Query(req.Query)
+	}
+	if req.Headers != nil {
+		request.Headers = map[string]*string{}
+		for key, val := range req.Headers {
+			request.Headers[key] = tea.String(val)
+		}
+	}
+	runtime := &util.RuntimeOptions{}
+	timeout := req.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remain := time.Until(deadline); timeout <= 0 || remain < timeout {
+			timeout = remain
+		}
+	}
+	if timeout > 0 {
+		// SDK 的超时单位为毫秒，向上取整，避免早于 ctx 超时或者取整为 0 使用默认值
+		ms := int((timeout + time.Millisecond - 1) / time.Millisecond)
+		runtime.ReadTimeout = tea.Int(ms)
+		if d.options.ConnectTimeout <= 0 || timeout < d.options.ConnectTimeout {
+			runtime.ConnectTimeout = tea.Int(ms)
+		}
+	}
+
+	result, err := d.client.CallApi(params, request, runtime)
+	if err != nil {
+		if e, ok := err.(*tea.SDKError); ok && tea.StringValue(e.Code) != "" {
+			return nil, NewError(err, tea.StringValue(e.Code), tea.StringValue(e.Message))
+		}
+		return nil, timeoutError(ctx, errors.Wrap(err, "client.CallApi failed"))
+	}
+
+	// 部分接口出错时 http 状态码仍为 200，错误码在 body 的 Code 中
+	if body, ok := result["body"].(map[string]interface{}); ok {
+		if code := cast.ToString(body["Code"]); isAliyunOpenAPIErrorCode(code, body["Success"]) {
+			return nil, NewError(nil, code, cast.ToString(body["Message"]))
+		}
+	}
+
+	res := &AliyunOpenAPIDoRes{
+		StatusCode: cast.ToInt(result["statusCode"]),
+		Headers:    cast.ToStringMapString(result["headers"]),
+		Body:       result["body"],
+	}
+	return res, nil
+}
+
+// isAliyunOpenAPIErrorCode 判断 body 中的 Code 是否表示失败，Success 为 true 或者 Code 为 OK/200/Success 时表示成功
+func isAliyunOpenAPIErrorCode(code string, success interface{}) bool {
+	if code == "" || (success != nil && cast.ToBool(success)) {
+		return false
+	}
+	switch strings.ToLower(code) {
+	case "ok", "200", "success":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	openapiutil "github.com/alibabacloud-go/openapi-util/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAliyunOpenAPIDriver(t *testing.T) {
+	Convey("TestAliyunOpenAPIDriver", t, func() {
+		// 服务端按照 RPC 和 ROA 的规则校验签名，签名错误或者 Action 为 Fail 时返回 OpenAPI 格式的错误
+		// Action 为 Busy 时状态码为 200，错误码在 body 中，Action 为 Slow 时延迟 1s 返回
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			fail := func(status int, code string) {
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"Code": "` + code + `", "Message": "request failed", "RequestId": "req-1"}`))
+			}
+
+			if r.URL.Path == "/" {
+				query := r.URL.Query()
+				form, _ := url.ParseQuery(string(body))
+				params := map[string]*string{}
+				for _, values := range []url.Values{query, form} {
+					for key := range values {
+						if key != "Signature" {
+							params[key] = tea.String(values.Get(key))
+						}
+					}
+				}
+				if query.Get("Signature") != tea.StringValue(openapiutil.GetRPCSignature(params, tea.String(r.Method), tea.String("sk"))) {
+					fail(http.StatusForbidden, "SignatureDoesNotMatch")
+					return
+				}
+				switch query.Get("Action") {
+				case "Fail":
+					fail(http.StatusBadRequest, "InvalidParameter")
+					return
+				case "Busy":
+					fail(http.StatusOK, "Throttling")
+					return
+				case "Slow":
+					time.Sleep(time.Second)
+				case "Ok":
+					_, _ = w.Write([]byte(`{"Code": "OK", "Message": "success", "RequestId": "req-1"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"RequestId": "req-1", "Action": "` + query.Get("Action") + `", "RegionId": "` + form.Get("RegionId") + `", "Filter": "` + form.Get("Filter.1.Name") + `"}`))
+				return
+			}
+
+			treq := tea.NewRequest()
+			treq.Method = tea.String(r.Method)
+			treq.Pathname = tea.String(r.URL.Path)
+			treq.Query = map[string]*string{}
+			for key := range r.URL.Query() {
+				treq.Query[key] = tea.String(r.URL.Query().Get(key))
+			}
+			for key := range r.Header {
+				treq.Headers[strings.ToLower(key)] = tea.String(r.Header.Get(key))
+			}
+			signature := openapiutil.GetROASignature(openapiutil.GetStringToSign(treq), tea.String("sk"))
+			if r.Header.Get("Authorization") != "acs ak:"+tea.StringValue(signature) {
+				fail(http.StatusForbidden, "SignatureDoesNotMatch")
+				return
+			}
+			_, _ = w.Write([]byte(`{"Path": "` + r.URL.Path + `", "Version": "` + r.Header.Get("x-acs-version") + `", "Body": ` + string(body) + `}`))
+		}))
+		defer server.Close()
+
+		newDriver := func(secret string) Driver {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "AliyunOpenAPI", Options: &AliyunOpenAPIDriverOptions{
+				AccessKeyId:     "ak",
+				AccessKeySecret: secret,
+				Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+				Protocol:        "HTTP",
+			}})
+			So(err, ShouldBeNil)
+			return d
+		}
+		d := newDriver("sk")
+
+		Convey("rpc", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Action":  "DescribeInstances",
+				"Version": "2014-05-26",
+				"Body": map[string]interface{}{
+					"RegionId": "cn-shanghai",
+					"Filter":   []interface{}{map[string]interface{}{"Name": "status"}},
+				},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["StatusCode"], ShouldEqual, 200)
+			So(res.(map[string]interface{})["Body"], ShouldResemble, map[string]interface{}{
+				"RequestId": "req-1",
+				"Action":    "DescribeInstances",
+				"RegionId":  "cn-shanghai",
+				"Filter":    "status",
+			})
+		})
+
+		Convey("roa", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Action":   "CreateCluster",
+				"Version":  "2015-12-15",
+				"Style":    "ROA",
+				"Pathname": "/clusters",
+				"Query":    map[string]interface{}{"dryRun": true},
+				"Body":     map[string]interface{}{"name": "test"},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Body"], ShouldResemble, map[string]interface{}{
+				"Path":    "/clusters",
+				"Version": "2015-12-15",
+				"Body":    map[string]interface{}{"name": "test"},
+			})
+		})
+
+		Convey("error code", func() {
+			_, err := d.Do(map[string]interface{}{"Action": "Fail", "Version": "2014-05-26"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "InvalidParameter")
+			So(err.Error(), ShouldContainSubstring, "req-1")
+
+			_, err = newDriver("wrong").Do(map[string]interface{}{"Action": "DescribeRegions", "Version": "2014-05-26"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "SignatureDoesNotMatch")
+
+			_, err = d.Do(map[string]interface{}{"Action": "DescribeRegions", "Style": "GraphQL"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "aliyun.InvalidRequest")
+		})
+
+		Convey("error code in body", func() {
+			_, err := d.Do(map[string]interface{}{"Action": "Busy", "Version": "2014-05-26"})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "Throttling")
+			So(errors.Cause(err).(*Error).Message, ShouldEqual, "request failed")
+		})
+
+		Convey("success code in body", func() {
+			res, err := d.Do(map[string]interface{}{"Action": "Ok", "Version": "2014-05-26"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["StatusCode"], ShouldEqual, 200)
+			So(res.(map[string]interface{})["Body"].(map[string]interface{})["Code"], ShouldEqual, "OK")
+		})
+
+		Convey("ctx deadline", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			now := time.Now()
+			_, err := d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Action": "Slow", "Version": "2014-05-26"})
+			So(err, ShouldNotBeNil)
+			So(time.Since(now), ShouldBeLessThan, 800*time.Millisecond)
+
+			_, err = d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Action": "DescribeRegions", "Version": "2014-05-26"})
+			So(errors.Cause(err), ShouldResemble, context.DeadlineExceeded)
+		})
+
+		Convey("invalid options", func() {
+			_, err := NewDriverWithOptions(&refx.TypeOptions{Type: "AliyunOpenAPI", Options: &AliyunOpenAPIDriverOptions{}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,311 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+type CoProcessDriverOptions struct {
+	// 常驻的子进程，从 stdin 逐行读取 json 请求，向 stdout 逐行写入 json 响应，stdin 关闭时应该退出
+	Command string
+	Args    []string
+	Envs    map[string]string
+	Dir     string
+	// VU 每个 VU 独占一个子进程，Pool 所有 VU 共享 PoolSize 个子进程
+	Scope    string `dft:"VU"`
+	PoolSize int    `dft:"10"`
+	// 等待响应的时间，超时后杀掉子进程，下次请求时重新启动
+	Timeout time.Duration `dft:"6s"`
+}
+
+func NewCoProcessDriverWithOptions(options *CoProcessDriverOptions) (*CoProcessDriver, error) {
+	if options.Command == "" {
+		return nil, errors.New("Command is required")
+	}
+	switch options.Scope {
+	case "":
+		options.Scope = "VU"
+	case "VU", "Pool":
+	default:
+		return nil, errors.Errorf("unsupported scope [%s]", options.Scope)
+	}
+	if options.PoolSize <= 0 {
+		options.PoolSize = 10
+	}
+
+	env := os.Environ()
+	for k, v := range options.Envs {
+		env = append(env, fmt.Sprintf(`%s=%s`, k, strings.TrimSpace(v)))
+	}
+
+	return &CoProcessDriver{
+		options: options,
+		env:     env,
+		sem:     make(chan struct{}, options.PoolSize),
+		idle:    make(chan *coProcess, options.PoolSize),
+	}, nil
+}
+
+// CoProcessDriver 通过常驻的子进程执行请求，避免每次请求 fork 进程的开销，子进程可以用任意语言实现
+//
+// 请求为 CoProcessDoReq.Req 序列化后的一行 json，响应为一行 json {"Res": ..., "Err": "...", "Code": "..."}，Err 不为空时表示请求失败
+type CoProcessDriver struct {
+	options *CoProcessDriverOptions
+	env     []string
+
+	// Pool 模式下 sem 限制子进程总数，idle 保存空闲的子进程
+	sem  chan struct{}
+	idle chan *coProcess
+	// Close 之后归还的子进程直接杀掉
+	mutex  sync.Mutex
+	closed bool
+}
+
+type CoProcessDoReq struct {
+	Req     interface{}
+	Timeout time.Duration
+}
+
+type CoProcessDoRes struct {
+	Res interface{}
+	// 本次请求启动了新的子进程，首次请求或者子进程崩溃之后
+	Started bool
+}
+
+type coProcessReply struct {
+	Res  interface{}
+	Err  string
+	Code string
+}
+
+type coProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	stderr *limitedBuffer
+	// 子进程退出的原因，lines 关闭后可读
+	err error
+
+	once   sync.Once
+	killed chan struct{}
+}
+
+func (p *coProcess) readLoop(stdout io.Reader) {
+	defer close(p.lines)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) != 0 {
+			select {
+			case p.lines <- line:
+			case <-p.killed:
+			}
+		}
+		if err != nil {
+			// 读完 stdout 之后才能调用 Wait
+			p.err = p.cmd.Wait()
+			if p.err == nil {
+				p.err = io.EOF
+			}
+			return
+		}
+	}
+}
+
+func (p *coProcess) kill() {
+	p.once.Do(func() {
+		close(p.killed)
+		_ = p.stdin.Close()
+		killProcessGroup(p.cmd)
+	})
+}
+
+func (d *CoProcessDriver) Do(ctx context.Context, req *CoProcessDoReq) (*CoProcessDoRes, error) {
+	buf, err := jsoniter.Marshal(req.Req)
+	if err != nil {
+		return nil, NewErrorf(err, "coprocess.InvalidRequest", "marshal Req failed, err: [%s]", err.Error())
+	}
+	buf = append(buf, '\n')
+
+	res := &CoProcessDoRes{}
+	p, release, err := d.get(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := d.call(ctx, p, buf, req.Timeout)
+	// 出错时子进程的状态未知，杀掉后下次请求重新启动
+	release(err != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Err != "" {
+		code := reply.Code
+		if code == "" {
+			code = "coprocess.Error"
+		}
+		return nil, NewError(nil, code, reply.Err)
+	}
+	res.Res = reply.Res
+	return res, nil
+}
+
+func (d *CoProcessDriver) call(ctx context.Context, p *coProcess, buf []byte, timeout time.Duration) (*coProcessReply, error) {
+	if timeout == 0 {
+		timeout = d.options.Timeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// 子进程不读 stdin 时管道写满会阻塞，写入也要受超时控制，超时后杀掉子进程会让写入返回
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(buf)
+		written <- err
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "write request failed")
+	case <-timer.C:
+		return nil, NewErrorf(nil, ErrCodeTimeout, "write request timeout in %v", timeout)
+	case err := <-written:
+		if err != nil {
+			return nil, d.crashed(p, err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "wait for response failed")
+	case <-timer.C:
+		return nil, NewErrorf(nil, ErrCodeTimeout, "no response in %v", timeout)
+	case line, ok := <-p.lines:
+		if !ok {
+			return nil, d.crashed(p, p.err)
+		}
+		var reply coProcessReply
+		if err := jsoniter.Unmarshal(line, &reply); err != nil {
+			return nil, NewErrorf(err, "coprocess.InvalidResponse", "unmarshal response [%s] failed, err: [%s]", strings.TrimSpace(string(line)), err.Error())
+		}
+		return &reply, nil
+	}
+}
+
+// crashed 等待子进程退出，返回带有 stderr 输出的错误
+func (d *CoProcessDriver) crashed(p *coProcess, err error) error {
+	p.kill()
+	for range p.lines {
+	}
+	if p.err != nil {
+		err = p.err
+	}
+	return NewErrorf(err, "coprocess.Crashed", "process exited, err: [%v], stderr: [%s]", err, strings.TrimSpace(p.stderr.buf.String()))
+}
+
+// get 返回当前请求使用的子进程，release 归还子进程，broken 为 true 时杀掉子进程
+func (d *CoProcessDriver) get(ctx context.Context, res *CoProcessDoRes) (*coProcess, func(broken bool), error) {
+	if session := VUSession(ctx); d.options.Scope == "VU" && session != nil {
+		var p *coProcess
+		if v, ok := session.Get(d); ok {
+			p = v.(*coProcess)
+		} else {
+			var err error
+			if p, err = d.start(); err != nil {
+				return nil, nil, err
+			}
+			res.Started = true
+			session.Set(d, p, p.kill)
+		}
+		return p, func(broken bool) {
+			if broken {
+				session.Delete(d)
+				p.kill()
+			}
+		}, nil
+	}
+
+	// 不在 VU 中执行时也使用进程池
+	select {
+	case d.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, errors.Wrap(ctx.Err(), "wait for process failed")
+	}
+	var p *coProcess
+	select {
+	case p = <-d.idle:
+	default:
+		var err error
+		if p, err = d.start(); err != nil {
+			<-d.sem
+			return nil, nil, err
+		}
+		res.Started = true
+	}
+	return p, func(broken bool) {
+		d.mutex.Lock()
+		if broken || d.closed {
+			p.kill()
+		} else {
+			d.idle <- p
+		}
+		d.mutex.Unlock()
+		<-d.sem
+	}, nil
+}
+
+// Close 杀掉进程池中的子进程，正在使用的子进程归还时杀掉，VU 独占的子进程随 VU 的 session 关闭
+func (d *CoProcessDriver) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.closed = true
+	for {
+		select {
+		case p := <-d.idle:
+			p.kill()
+		default:
+			return nil
+		}
+	}
+}
+
+func (d *CoProcessDriver) start() (*coProcess, error) {
+	cmd := exec.Command(d.options.Command, d.options.Args...)
+	cmd.Env = d.env
+	cmd.Dir = d.options.Dir
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "cmd.StdinPipe failed")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "cmd.StdoutPipe failed")
+	}
+	p := &coProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte, 1),
+		stderr: &limitedBuffer{limit: 4096},
+		killed: make(chan struct{}),
+	}
+	cmd.Stderr = p.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, NewErrorf(err, "coprocess.StartFailed", "start [%s] failed, err: [%s]", d.options.Command, err.Error())
+	}
+	go p.readLoop(stdout)
+
+	return p, nil
+}
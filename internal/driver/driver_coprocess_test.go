@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// coProcessAdapter 返回请求以及进程号，请求中包含 crash，sleep，error 时分别模拟崩溃，超时和失败
+const coProcessAdapter = `
+while read -r line; do
+  case "$line" in
+    *crash*) echo "crashed by request" >&2; exit 1;;
+    *sleep*) sleep 5;;
+    *error*) echo '{"Err": "bad request", "Code": "BadRequest"}';;
+    *) echo "{\"Res\": {\"req\": $line, \"pid\": $$}}";;
+  esac
+done
+`
+
+func TestCoProcessDriver(t *testing.T) {
+	Convey("TestCoProcessDriver", t, func() {
+		newDriver := func(scope string) ContextDriver {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type: "CoProcess",
+				Options: &CoProcessDriverOptions{
+					Command:  "bash",
+					Args:     []string{"-c", coProcessAdapter},
+					Scope:    scope,
+					PoolSize: 2,
+					Timeout:  time.Second,
+				},
+			})
+			So(err, ShouldBeNil)
+			return d.(ContextDriver)
+		}
+		do := func(d ContextDriver, ctx context.Context, req interface{}) (map[string]interface{}, error) {
+			res, err := d.DoContext(ctx, map[string]interface{}{"Req": req, "Timeout": "200ms"})
+			if err != nil {
+				return nil, err
+			}
+			return res.(map[string]interface{}), nil
+		}
+
+		Convey("vu", func() {
+			d := newDriver("VU")
+			session1 := NewSession()
+			defer session1.Close()
+			session2 := NewSession()
+			defer session2.Close()
+			ctx1 := WithVUSession(context.Background(), session1)
+			ctx2 := WithVUSession(context.Background(), session2)
+
+			res, err := do(d, ctx1, map[string]interface{}{"key": "val"})
+			So(err, ShouldBeNil)
+			So(res["Started"], ShouldBeTrue)
+			So(res["Res"].(map[string]interface{})["req"], ShouldResemble, map[string]interface{}{"key": "val"})
+			pid := res["Res"].(map[string]interface{})["pid"]
+
+			res, err = do(d, ctx1, 1)
+			So(err, ShouldBeNil)
+			So(res["Started"], ShouldBeFalse)
+			So(res["Res"].(map[string]interface{})["pid"], ShouldEqual, pid)
+
+			res, err = do(d, ctx2, 2)
+			So(err, ShouldBeNil)
+			So(res["Started"], ShouldBeTrue)
+			So(res["Res"].(map[string]interface{})["pid"], ShouldNotEqual, pid)
+
+			_, err = do(d, ctx1, "error")
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "BadRequest")
+			res, err = do(d, ctx1, 3)
+			So(err, ShouldBeNil)
+			So(res["Res"].(map[string]interface{})["pid"], ShouldEqual, pid)
+
+			Convey("restart after crash", func() {
+				_, err := do(d, ctx1, "crash")
+				So(errors.Cause(err).(*Error).Code, ShouldEqual, "coprocess.Crashed")
+				So(err.Error(), ShouldContainSubstring, "crashed by request")
+
+				res, err := do(d, ctx1, 4)
+				So(err, ShouldBeNil)
+				So(res["Started"], ShouldBeTrue)
+				So(res["Res"].(map[string]interface{})["pid"], ShouldNotEqual, pid)
+			})
+
+			Convey("restart after timeout", func() {
+				now := time.Now()
+				_, err := do(d, ctx1, "sleep")
+				So(time.Since(now), ShouldBeLessThan, time.Second)
+				So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+
+				res, err := do(d, ctx1, 5)
+				So(err, ShouldBeNil)
+				So(res["Started"], ShouldBeTrue)
+			})
+		})
+
+		Convey("pool", func() {
+			d := newDriver("Pool")
+			pids := map[interface{}]bool{}
+			for i := 0; i < 5; i++ {
+				res, err := do(d, context.Background(), i)
+				So(err, ShouldBeNil)
+				pids[res["Res"].(map[string]interface{})["pid"]] = true
+			}
+			So(len(pids), ShouldEqual, 1)
+
+			Convey("close", func() {
+				inner := d.(*WrapDriver).inner.Interface().(*CoProcessDriver)
+				p := <-inner.idle
+				inner.idle <- p
+				So(Close(d), ShouldBeNil)
+				So(inner.idle, ShouldBeEmpty)
+				_, ok := <-p.killed
+				So(ok, ShouldBeFalse)
+
+				// Close 之后归还的子进程也会被杀掉
+				res, err := do(d, context.Background(), 6)
+				So(err, ShouldBeNil)
+				So(res["Started"], ShouldBeTrue)
+				So(inner.idle, ShouldBeEmpty)
+			})
+		})
+
+		Convey("write timeout", func() {
+			// 子进程不读 stdin，请求大于管道缓冲区时写入会阻塞
+			d, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "CoProcess",
+				Options: &CoProcessDriverOptions{Command: "sleep", Args: []string{"30"}, Scope: "Pool"},
+			})
+			So(err, ShouldBeNil)
+			defer Close(d)
+
+			now := time.Now()
+			_, err = do(d.(ContextDriver), context.Background(), strings.Repeat("x", 1<<20))
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+		})
+
+		Convey("invalid options", func() {
+			_, err := NewDriverWithOptions(&refx.TypeOptions{Type: "CoProcess", Options: &CoProcessDriverOptions{}})
+			So(err, ShouldNotBeNil)
+			_, err = NewDriverWithOptions(&refx.TypeOptions{Type: "CoProcess", Options: &CoProcessDriverOptions{Command: "bash", Scope: "Unit"}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,375 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/grpcreflect"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/hatlonely/benv2/internal/recorder"
+)
+
+type GrpcDriverOptions struct {
+	Target             string
+	DialTimeout        time.Duration `dft:"3s"`
+	Timeout            time.Duration `dft:"6s"`
+	TLS                bool
+	InsecureSkipVerify bool
+
+	// 服务定义来源，Protoset 为 protoc --descriptor_set_out 生成的文件，ProtoFiles 为 .proto 文件
+	// 都没有配置时通过服务端反射获取
+	Protoset    []string
+	ProtoFiles  []string
+	ImportPaths []string
+
+	// 不为空时将框架生成的 trace id 放在这个 metadata 中
+	TraceMetadata string
+}
+
+func NewGrpcDriverWithOptions(options *GrpcDriverOptions) (*GrpcDriver, error) {
+	services := map[string]*desc.ServiceDescriptor{}
+	for _, filename := range options.Protoset {
+		buf, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ioutil.ReadFile [%s] failed", filename)
+		}
+		var fds descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(buf, &fds); err != nil {
+			return nil, errors.Wrapf(err, "proto.Unmarshal [%s] failed", filename)
+		}
+		fdMap, err := desc.CreateFileDescriptorsFromSet(&fds)
+		if err != nil {
+			return nil, errors.Wrapf(err, "desc.CreateFileDescriptorsFromSet [%s] failed", filename)
+		}
+		for _, fd := range fdMap {
+			for _, sd := range fd.GetServices() {
+				services[sd.GetFullyQualifiedName()] = sd
+			}
+		}
+	}
+	if len(options.ProtoFiles) != 0 {
+		parser := protoparse.Parser{ImportPaths: options.ImportPaths}
+		fds, err := parser.ParseFiles(options.ProtoFiles...)
+		if err != nil {
+			return nil, errors.Wrap(err, "parser.ParseFiles failed")
+		}
+		for _, fd := range fds {
+			for _, sd := range fd.GetServices() {
+				services[sd.GetFullyQualifiedName()] = sd
+			}
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if options.TLS {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: options.InsecureSkipVerify})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), options.DialTimeout)
+	defer cancel()
+	// 阻塞到连接建立，目标不可达时在 DialTimeout 后返回错误，而不是在第一次请求时
+	conn, err := grpc.DialContext(ctx, options.Target, grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	if err != nil {
+		return nil, errors.Wrap(err, "grpc.DialContext failed")
+	}
+
+	d := &GrpcDriver{
+		conn:     conn,
+		options:  options,
+		services: services,
+	}
+	if len(options.Protoset) == 0 && len(options.ProtoFiles) == 0 {
+		d.reflectClient = grpcreflect.NewClientAuto(context.Background(), conn)
+	}
+
+	return d, nil
+}
+
+type GrpcDriver struct {
+	conn          *grpc.ClientConn
+	options       *GrpcDriverOptions
+	services      map[string]*desc.ServiceDescriptor
+	reflectClient *grpcreflect.Client
+
+	// key 为 /package.Service/Method
+	methods sync.Map
+}
+
+func (d *GrpcDriver) Close() error {
+	if d.reflectClient != nil {
+		d.reflectClient.Reset()
+	}
+	return d.conn.Close()
+}
+
+type GrpcDoReq struct {
+	// package.Service/Method
+	Method string
+	Req    interface{}
+	// 客户端流和双向流依次发送的消息，为空时发送 Req
+	Reqs     []interface{}
+	Metadata map[string]string
+	Timeout  time.Duration
+}
+
+type GrpcDoRes struct {
+	Code   int
+	Status string
+	// 流式调用时为最后一条响应
+	Res interface{}
+	// 流式调用收到的所有响应
+	Ress     []interface{}
+	Headers  map[string]string
+	Trailers map[string]string
+	Stream   *recorder.StreamStat
+}
+
+func (d *GrpcDriver) Do(ctx context.Context, req *GrpcDoReq) (*GrpcDoRes, error) {
+	fullMethod, md, err := d.resolveMethod(req.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := d.withTimeout(ctx, req.Timeout)
+	defer cancel()
+	for key, val := range req.Metadata {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, val)
+	}
+	if trace := TraceFromContext(ctx); trace != nil && d.options.TraceMetadata != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, d.options.TraceMetadata, trace.ID)
+	}
+
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return d.doStream(ctx, fullMethod, md, req)
+	}
+
+	in, err := newMessage(md.Input(), req.Req)
+	if err != nil {
+		return nil, err
+	}
+	out := dynamicpb.NewMessage(md.Output())
+
+	var header, trailer metadata.MD
+	if err := d.conn.Invoke(ctx, fullMethod, in, out, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		return nil, grpcError(err)
+	}
+
+	res, err := messageToInterface(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GrpcDoRes{
+		Code:     0,
+		Status:   "OK",
+		Res:      res,
+		Headers:  metadataToMap(header),
+		Trailers: metadataToMap(trailer),
+	}, nil
+}
+
+func (d *GrpcDriver) doStream(ctx context.Context, fullMethod string, md protoreflect.MethodDescriptor, req *GrpcDoReq) (*GrpcDoRes, error) {
+	reqs := req.Reqs
+	if len(reqs) == 0 {
+		reqs = []interface{}{req.Req}
+	}
+	if !md.IsStreamingClient() && len(reqs) != 1 {
+		return nil, NewErrorf(nil, "grpc.InvalidRequest", "method [%s] accepts only one message", fullMethod)
+	}
+	var ins []proto.Message
+	for _, r := range reqs {
+		in, err := newMessage(md.Input(), r)
+		if err != nil {
+			return nil, err
+		}
+		ins = append(ins, in)
+	}
+
+	start := time.Now()
+	stream, err := d.conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ClientStreams: md.IsStreamingClient(),
+		ServerStreams: md.IsStreamingServer(),
+	}, fullMethod)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	// 双向流的发送和接收同时进行，出错返回时 Do 中 ctx 的 cancel 会结束发送协程
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, in := range ins {
+			// 服务端提前结束时返回 io.EOF，真实的状态由 RecvMsg 返回
+			if err := stream.SendMsg(in); err != nil {
+				if err == io.EOF {
+					break
+				}
+				sendErr <- grpcError(err)
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	var stat recorder.StreamStat
+	var ress []interface{}
+	var last time.Time
+	for {
+		out := dynamicpb.NewMessage(md.Output())
+		if err := stream.RecvMsg(out); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, grpcError(err)
+		}
+		now := time.Now()
+		if stat.MessageCount == 0 {
+			stat.FirstMessageTime = now.Sub(start)
+		} else if gap := now.Sub(last); gap > stat.MaxMessageGap {
+			stat.MaxMessageGap = gap
+		}
+		last = now
+		stat.MessageCount++
+		res, err := messageToInterface(out)
+		if err != nil {
+			return nil, err
+		}
+		ress = append(ress, res)
+		// 客户端流只有一条响应
+		if !md.IsStreamingServer() {
+			break
+		}
+	}
+	stat.Duration = time.Since(start)
+	if err := <-sendErr; err != nil {
+		return nil, errors.Wrap(err, "stream.SendMsg failed")
+	}
+
+	header, _ := stream.Header()
+	var res interface{}
+	if len(ress) != 0 {
+		res = ress[len(ress)-1]
+	}
+
+	return &GrpcDoRes{
+		Code:     0,
+		Status:   "OK",
+		Res:      res,
+		Ress:     ress,
+		Headers:  metadataToMap(header),
+		Trailers: metadataToMap(stream.Trailer()),
+		Stream:   &stat,
+	}, nil
+}
+
+func (d *GrpcDriver) withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = d.options.Timeout
+	}
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (d *GrpcDriver) resolveMethod(method string) (string, protoreflect.MethodDescriptor, error) {
+	method = strings.TrimPrefix(method, "/")
+	fullMethod := "/" + method
+	if v, ok := d.methods.Load(fullMethod); ok {
+		return fullMethod, v.(protoreflect.MethodDescriptor), nil
+	}
+
+	idx := strings.LastIndexByte(method, '/')
+	if idx == -1 {
+		return "", nil, NewErrorf(nil, "grpc.InvalidMethod", "method should be package.Service/Method. method: [%s]", method)
+	}
+	serviceName, methodName := method[:idx], method[idx+1:]
+
+	sd, ok := d.services[serviceName]
+	if !ok && d.reflectClient != nil {
+		var err error
+		sd, err = d.reflectClient.ResolveService(serviceName)
+		if err != nil {
+			// 反射流出错后需要重置，下次请求重新建立
+			d.reflectClient.Reset()
+			return "", nil, NewErrorf(err, "grpc.ResolveServiceFailed", "reflectClient.ResolveService failed, err: [%s]", err.Error())
+		}
+		ok = true
+	}
+	if !ok {
+		return "", nil, NewErrorf(nil, "grpc.NoSuchService", "no such service [%s]", serviceName)
+	}
+	md := sd.FindMethodByName(methodName)
+	if md == nil {
+		return "", nil, NewErrorf(nil, "grpc.NoSuchMethod", "no such method [%s]", method)
+	}
+
+	d.methods.Store(fullMethod, md.UnwrapMethod())
+	return fullMethod, md.UnwrapMethod(), nil
+}
+
+// grpcError 将 grpc 的状态码转换成 driver.Error 的错误码，如 NotFound，Unavailable
+func grpcError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return errors.Wrap(err, "conn.Invoke failed")
+	}
+	if st.Code() == codes.DeadlineExceeded {
+		return NewError(err, ErrCodeTimeout, st.Message())
+	}
+	return NewError(err, st.Code().String(), st.Message())
+}
+
+func newMessage(md protoreflect.MessageDescriptor, v interface{}) (proto.Message, error) {
+	m := dynamicpb.NewMessage(md)
+	if v == nil {
+		return m, nil
+	}
+	buf, err := jsoniter.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "jsoniter.Marshal failed")
+	}
+	if err := protojson.Unmarshal(buf, m); err != nil {
+		return nil, NewErrorf(err, "grpc.InvalidRequest", "protojson.Unmarshal failed, err: [%s]", err.Error())
+	}
+	return m, nil
+}
+
+func messageToInterface(m proto.Message) (interface{}, error) {
+	buf, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "protojson.Marshal failed")
+	}
+	var v interface{}
+	if err := jsoniter.Unmarshal(buf, &v); err != nil {
+		return nil, errors.Wrap(err, "jsoniter.Unmarshal failed")
+	}
+	return v, nil
+}
+
+func metadataToMap(md metadata.MD) map[string]string {
+	m := map[string]string{}
+	for key, vals := range md {
+		m[key] = strings.Join(vals, ",")
+	}
+	return m
+}
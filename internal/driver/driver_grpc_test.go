@@ -0,0 +1,321 @@
+package driver
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var healthProto = `syntax = "proto3";
+
+package grpc.health.v1;
+
+message HealthCheckRequest {
+  string service = 1;
+}
+
+message HealthCheckResponse {
+  enum ServingStatus {
+    UNKNOWN = 0;
+    SERVING = 1;
+    NOT_SERVING = 2;
+    SERVICE_UNKNOWN = 3;
+  }
+  ServingStatus status = 1;
+}
+
+service Health {
+  rpc Check(HealthCheckRequest) returns (HealthCheckResponse);
+  rpc Watch(HealthCheckRequest) returns (stream HealthCheckResponse);
+}
+`
+
+func TestGrpcDriver(t *testing.T) {
+	Convey("TestGrpcDriver", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		server := grpc.NewServer()
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("benv", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		grpc_health_v1.RegisterHealthServer(server, healthServer)
+		reflection.Register(server)
+		go server.Serve(listener)
+		defer server.Stop()
+
+		dir, err := ioutil.TempDir("", "benv-grpc")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		fdp := protodesc.ToFileDescriptorProto(grpc_health_v1.File_grpc_health_v1_health_proto)
+		buf, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdp}})
+		So(err, ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "health.protoset"), buf, 0644), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "health.proto"), []byte(healthProto), 0644), ShouldBeNil)
+
+		for _, options := range []*GrpcDriverOptions{
+			{Target: listener.Addr().String()},
+			{Target: listener.Addr().String(), Protoset: []string{filepath.Join(dir, "health.protoset")}},
+			{Target: listener.Addr().String(), ProtoFiles: []string{"health.proto"}, ImportPaths: []string{dir}},
+		} {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "Grpc",
+				Options: options,
+			})
+			So(err, ShouldBeNil)
+
+			res, err := d.Do(map[string]interface{}{
+				"Method": "grpc.health.v1.Health/Check",
+				"Req":    map[string]interface{}{},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Code"], ShouldEqual, int64(0))
+			So(res.(map[string]interface{})["Res"], ShouldResemble, map[string]interface{}{
+				"status": "SERVING",
+			})
+
+			res, err = d.Do(map[string]interface{}{
+				"Method": "grpc.health.v1.Health/Check",
+				"Req": map[string]interface{}{
+					"service": "benv",
+				},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldResemble, map[string]interface{}{
+				"status": "NOT_SERVING",
+			})
+
+			_, err = d.Do(map[string]interface{}{
+				"Method": "grpc.health.v1.Health/Check",
+				"Req": map[string]interface{}{
+					"service": "unknown",
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "NotFound")
+
+			_, err = d.Do(map[string]interface{}{
+				"Method": "grpc.health.v1.Health/Unknown",
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "grpc.NoSuchMethod")
+
+			So(Close(d), ShouldBeNil)
+		}
+
+		Convey("unreachable target", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			target := listener.Addr().String()
+			So(listener.Close(), ShouldBeNil)
+
+			now := time.Now()
+			_, err = NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "Grpc",
+				Options: &GrpcDriverOptions{Target: target, DialTimeout: 200 * time.Millisecond},
+			})
+			So(err, ShouldNotBeNil)
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+		})
+	})
+}
+
+var echoProto = `syntax = "proto3";
+
+package benv.test;
+
+message EchoReq {
+  string message = 1;
+  int32 count = 2;
+}
+
+message EchoRes {
+  string message = 1;
+  int32 index = 2;
+}
+
+service Echo {
+  rpc ServerStream(EchoReq) returns (stream EchoRes);
+  rpc ClientStream(stream EchoReq) returns (EchoRes);
+  rpc BidiStream(stream EchoReq) returns (stream EchoRes);
+}
+`
+
+func TestGrpcDriverStream(t *testing.T) {
+	Convey("TestGrpcDriverStream", t, func() {
+		dir, err := ioutil.TempDir("", "benv-grpc")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(ioutil.WriteFile(filepath.Join(dir, "echo.proto"), []byte(echoProto), 0644), ShouldBeNil)
+
+		parser := protoparse.Parser{ImportPaths: []string{dir}}
+		fds, err := parser.ParseFiles("echo.proto")
+		So(err, ShouldBeNil)
+		sd := fds[0].FindService("benv.test.Echo").UnwrapService()
+		reqDesc := sd.Methods().ByName("ServerStream").Input()
+		resDesc := sd.Methods().ByName("ServerStream").Output()
+
+		newRes := func(message string, index int) *dynamicpb.Message {
+			m := dynamicpb.NewMessage(resDesc)
+			m.Set(resDesc.Fields().ByName("message"), protoreflect.ValueOfString(message))
+			m.Set(resDesc.Fields().ByName("index"), protoreflect.ValueOfInt32(int32(index)))
+			return m
+		}
+		field := func(m *dynamicpb.Message, name string) protoreflect.Value {
+			return m.Get(reqDesc.Fields().ByName(protoreflect.Name(name)))
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		server := grpc.NewServer()
+		server.RegisterService(&grpc.ServiceDesc{
+			ServiceName: "benv.test.Echo",
+			HandlerType: (*interface{})(nil),
+			Streams: []grpc.StreamDesc{{
+				StreamName:    "ServerStream",
+				ServerStreams: true,
+				Handler: func(srv interface{}, stream grpc.ServerStream) error {
+					req := dynamicpb.NewMessage(reqDesc)
+					if err := stream.RecvMsg(req); err != nil {
+						return err
+					}
+					for i := 0; i < int(field(req, "count").Int()); i++ {
+						if err := stream.SendMsg(newRes(field(req, "message").String(), i)); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			}, {
+				StreamName:    "ClientStream",
+				ClientStreams: true,
+				Handler: func(srv interface{}, stream grpc.ServerStream) error {
+					var messages []string
+					for {
+						req := dynamicpb.NewMessage(reqDesc)
+						if err := stream.RecvMsg(req); err != nil {
+							if err == io.EOF {
+								break
+							}
+							return err
+						}
+						messages = append(messages, field(req, "message").String())
+					}
+					return stream.SendMsg(newRes(strings.Join(messages, ","), len(messages)))
+				},
+			}, {
+				StreamName:    "BidiStream",
+				ClientStreams: true,
+				ServerStreams: true,
+				Handler: func(srv interface{}, stream grpc.ServerStream) error {
+					for i := 0; ; i++ {
+						req := dynamicpb.NewMessage(reqDesc)
+						if err := stream.RecvMsg(req); err != nil {
+							if err == io.EOF {
+								return nil
+							}
+							return err
+						}
+						if err := stream.SendMsg(newRes(field(req, "message").String(), i)); err != nil {
+							return err
+						}
+					}
+				},
+			}},
+		}, nil)
+		go server.Serve(listener)
+		defer server.Stop()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "Grpc",
+			Options: &GrpcDriverOptions{
+				Target:      listener.Addr().String(),
+				ProtoFiles:  []string{"echo.proto"},
+				ImportPaths: []string{dir},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("server stream", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Method": "benv.test.Echo/ServerStream",
+				"Req": map[string]interface{}{
+					"message": "hello",
+					"count":   3,
+				},
+			})
+			So(err, ShouldBeNil)
+			m := res.(map[string]interface{})
+			So(m["Ress"], ShouldResemble, []interface{}{
+				map[string]interface{}{"message": "hello", "index": int64(0)},
+				map[string]interface{}{"message": "hello", "index": int64(1)},
+				map[string]interface{}{"message": "hello", "index": int64(2)},
+			})
+			So(m["Res"], ShouldResemble, map[string]interface{}{"message": "hello", "index": int64(2)})
+			So(m["Stream"].(map[string]interface{})["MessageCount"], ShouldEqual, int64(3))
+			So(m["Stream"].(map[string]interface{})["FirstMessageTime"], ShouldBeGreaterThan, int64(0))
+		})
+
+		Convey("client stream", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Method": "benv.test.Echo/ClientStream",
+				"Reqs": []interface{}{
+					map[string]interface{}{"message": "a"},
+					map[string]interface{}{"message": "b"},
+				},
+			})
+			So(err, ShouldBeNil)
+			m := res.(map[string]interface{})
+			So(m["Res"], ShouldResemble, map[string]interface{}{"message": "a,b", "index": int64(2)})
+			So(m["Stream"].(map[string]interface{})["MessageCount"], ShouldEqual, int64(1))
+		})
+
+		Convey("bidi stream", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Method": "benv.test.Echo/BidiStream",
+				"Reqs": []interface{}{
+					map[string]interface{}{"message": "a"},
+					map[string]interface{}{"message": "b"},
+				},
+			})
+			So(err, ShouldBeNil)
+			m := res.(map[string]interface{})
+			So(m["Ress"], ShouldResemble, []interface{}{
+				map[string]interface{}{"message": "a", "index": int64(0)},
+				map[string]interface{}{"message": "b", "index": int64(1)},
+			})
+			So(m["Stream"].(map[string]interface{})["MessageCount"], ShouldEqual, int64(2))
+		})
+
+		Convey("server stream accepts only one message", func() {
+			_, err := d.Do(map[string]interface{}{
+				"Method": "benv.test.Echo/ServerStream",
+				"Reqs": []interface{}{
+					map[string]interface{}{"message": "a"},
+					map[string]interface{}{"message": "b"},
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "grpc.InvalidRequest")
+		})
+	})
+}
@@ -1,86 +1,322 @@
 package driver
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/hatlonely/go-kit/refx"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
+
+	"github.com/hatlonely/benv2/internal/recorder"
 )
 
 type HttpDriverOptions struct {
 	DialTimeout         time.Duration `dft:"3s"`
 	Timeout             time.Duration `dft:"6s"`
 	MaxIdleConnsPerHost int           `dft:"2"`
+	// 每次请求新建连接，用于压测建连和握手的开销
+	DisableKeepAlives bool
+	// HTTP/1.1 或 HTTP/2，为空时 https 通过 ALPN 协商，HTTP/2 仅支持 https
+	Protocol string
+	TLS      HttpTLSOptions
+	// cookie 的作用域 VU/Unit，同一个 VU 或者 unit 的请求共享 cookie，为空时不保存 cookie
+	CookieScope string
+	// 请求发送前依次执行的签名，如 Basic，Bearer，Hmac，AwsV4，AliyunRpc，AliyunRoa
+	Signers []refx.TypeOptions
+	// 不为空时将框架生成的 trace id 放在这个 header 中
+	TraceHeader string
+}
+
+type HttpTLSOptions struct {
+	// 校验服务端证书的 CA 证书文件，为空时使用系统证书
+	CAFile string
+	// 双向认证的客户端证书和私钥文件
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+	// 覆盖 SNI 以及校验证书使用的域名
+	ServerName string
+	// 1.0/1.1/1.2/1.3
+	MinVersion string
 }
 
 func NewHttpDriverWithOptions(options *HttpDriverOptions) (*HttpDriver, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, netw, addr string) (net.Conn, error) {
-				c, err := net.DialTimeout(netw, addr, options.DialTimeout)
-				if err != nil {
-					return nil, err
-				}
-				return c, nil
-			},
-			MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
-		},
-		Timeout: options.Timeout,
+	tlsConfig, err := newTLSConfig(&options.TLS)
+	if err != nil {
+		return nil, errors.WithMessage(err, "newTLSConfig failed")
+	}
+
+	transport := &http.Transport{
+		// 使用请求的 ctx 建立连接，httptrace 才能记录 DNS 和建立连接的时间
+		DialContext:         (&net.Dialer{Timeout: options.DialTimeout}).DialContext,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		DisableKeepAlives:   options.DisableKeepAlives,
+		TLSClientConfig:     tlsConfig,
+		// 自定义了 DialContext 和 TLSClientConfig 之后默认不再尝试 HTTP/2
+		ForceAttemptHTTP2: true,
+	}
+	switch options.Protocol {
+	case "", "HTTP/2":
+	case "HTTP/1.1":
+		// TLSNextProto 不为 nil 时禁用 HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	default:
+		return nil, errors.Errorf("unsupported protocol [%s]", options.Protocol)
+	}
+	switch options.CookieScope {
+	case "", "VU", "Unit":
+	default:
+		return nil, errors.Errorf("unsupported cookie scope [%s]", options.CookieScope)
+	}
+	var signers []HttpSigner
+	for i := range options.Signers {
+		signer, err := NewHttpSignerWithOptions(&options.Signers[i])
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewHttpSignerWithOptions failed")
+		}
+		signers = append(signers, signer)
 	}
 
 	return &HttpDriver{
-		client: client,
+		options: options,
+		signers: signers,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   options.Timeout,
+		},
 	}, nil
 }
 
+func newTLSConfig(options *HttpTLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+		ServerName:         options.ServerName,
+	}
+
+	switch options.MinVersion {
+	case "":
+	case "1.0":
+		tlsConfig.MinVersion = tls.VersionTLS10
+	case "1.1":
+		tlsConfig.MinVersion = tls.VersionTLS11
+	case "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, errors.Errorf("unsupported tls version [%s]", options.MinVersion)
+	}
+
+	if options.CAFile != "" {
+		buf, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ioutil.ReadFile [%s] failed", options.CAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return nil, errors.Errorf("no certificate found in [%s]", options.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "tls.LoadX509KeyPair failed")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 type HttpDriver struct {
-	client *http.Client
+	options *HttpDriverOptions
+	signers []HttpSigner
+	client  *http.Client
 }
 
 type HttpDoReq struct {
-	Method     string
-	URL        string
-	Params     map[string]string
-	Headers    map[string]string
-	Data       string
-	Json       interface{}
+	Method  string
+	URL     string
+	Params  map[string]string
+	Headers map[string]string
+	// 请求携带的 cookie，配置了 CookieScope 时同时写入 cookie jar，后续请求也会携带
+	Cookies map[string]string
+
+	// 请求 body，以下字段只能设置一个，Data 和 Json 同时设置时使用 Data
+	Data string
+	Json interface{}
+	// application/x-www-form-urlencoded 表单
+	Form map[string]string
+	// multipart/form-data 表单
+	Multipart []*HttpPart
+	// 二进制 body，Binary 为 base64 编码的数据，DataFile 为读取的文件路径
+	Binary   string
+	DataFile string
+
 	Timeout    time.Duration
 	JsonDecode bool
+	// 流式读取响应 SSE/NDJSON，解析出的事件放在 Events 中，并记录首个事件的时间和事件间隔
+	Stream string
+}
+
+// HttpPart multipart 表单的一个字段，Value，File，Size 只能设置一个
+type HttpPart struct {
+	Name  string
+	Value string
+	// 上传的文件路径
+	File string
+	// 上传 Size 字节生成的数据
+	Size int
+	// 文件名，默认为 File 的文件名
+	FileName    string
+	ContentType string
 }
 
 type HttpDoRes struct {
-	Status  int
+	Status int
+	// 实际使用的协议，如 HTTP/1.1，HTTP/2.0
+	Proto   string
 	Headers map[string]string
+	// 配置了 CookieScope 时为 cookie jar 中该 URL 可见的 cookie，否则为响应设置的 cookie
+	Cookies map[string]string
 	Json    interface{}
 	Text    string
+	// 复用了已经建立的连接，此时没有 DNS，Connect，TLSHandshake 的时间
+	ConnReused bool
+	Timing     *HttpTiming
+	// 流式读取时解析出的事件以及统计
+	Events []*HttpEvent
+	Stream *recorder.StreamStat
 }
 
-func (d *HttpDriver) Do(req *HttpDoReq) (*HttpDoRes, error) {
-	var buf []byte
-	if req.Json != nil {
-		var err error
-		buf, err = jsoniter.Marshal(req.Json)
-		if err != nil {
-			return nil, errors.WithMessage(err, "jsoniter.Marshal failed")
-		}
+// HttpEvent SSE 的一个事件或者 NDJSON 的一行，Json 为 Data 按 json 解析的结果
+type HttpEvent struct {
+	Event string
+	Id    string
+	Data  string
+	Json  interface{}
+	// 从发起请求到收到事件的时间
+	Time time.Duration
+}
+
+// HttpTiming 请求各个阶段的耗时
+type HttpTiming struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// 从请求发送完到收到第一个字节
+	TTFB time.Duration
+	// 从收到第一个字节到读完 body
+	Download time.Duration
+}
+
+type httpTracer struct {
+	mutex        sync.Mutex
+	timing       HttpTiming
+	reused       bool
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+}
+
+func (t *httpTracer) trace() *httptrace.ClientTrace {
+	// 同时尝试多个地址时回调可能并发执行
+	locked := func(fn func()) {
+		t.mutex.Lock()
+		fn()
+		t.mutex.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			locked(func() { t.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			locked(func() { t.timing.DNS = time.Since(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			locked(func() { t.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			locked(func() { t.timing.Connect = time.Since(t.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			locked(func() { t.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			locked(func() { t.timing.TLSHandshake = time.Since(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			locked(func() { t.reused = info.Reused })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			locked(func() { t.wroteRequest = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			locked(func() {
+				t.firstByte = time.Now()
+				t.timing.TTFB = t.firstByte.Sub(t.wroteRequest)
+			})
+		},
+	}
+}
+
+func (d *HttpDriver) Do(ctx context.Context, req *HttpDoReq) (*HttpDoRes, error) {
+	switch req.Stream {
+	case "", "SSE", "NDJSON":
+	default:
+		return nil, NewErrorf(nil, "http.InvalidRequest", "unknown Stream [%s]", req.Stream)
 	}
-	if req.Data != "" {
-		buf = []byte(req.Data)
+	buf, contentType, err := httpBody(req)
+	if err != nil {
+		return nil, err
 	}
 
-	hreq, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(buf))
+	reqCtx := ctx
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+	tracer := &httpTracer{}
+	reqCtx = httptrace.WithClientTrace(reqCtx, tracer.trace())
+	hreq, err := http.NewRequestWithContext(reqCtx, req.Method, req.URL, bytes.NewReader(buf))
 	if err != nil {
-		return nil, errors.WithMessage(err, "http.NewRequest failed")
+		return nil, errors.WithMessage(err, "http.NewRequestWithContext failed")
 	}
 
+	if contentType != "" {
+		hreq.Header.Set("Content-Type", contentType)
+	}
 	for key, val := range req.Headers {
 		hreq.Header.Set(key, val)
 	}
+	if trace := TraceFromContext(ctx); trace != nil && d.options.TraceHeader != "" {
+		hreq.Header.Set(d.options.TraceHeader, trace.ID)
+	}
 
 	if req.Params != nil {
 		q := hreq.URL.Query()
@@ -90,14 +326,44 @@ func (d *HttpDriver) Do(req *HttpDoReq) (*HttpDoRes, error) {
 		hreq.URL.RawQuery = q.Encode()
 	}
 
-	hres, err := d.client.Do(hreq)
+	client := d.client
+	jar := d.cookieJar(ctx)
+	if jar != nil {
+		// 共享 Transport，只替换 Jar
+		c := *d.client
+		c.Jar = jar
+		client = &c
+		var cookies []*http.Cookie
+		for name, val := range req.Cookies {
+			cookies = append(cookies, &http.Cookie{Name: name, Value: val})
+		}
+		jar.SetCookies(hreq.URL, cookies)
+	} else {
+		for name, val := range req.Cookies {
+			hreq.AddCookie(&http.Cookie{Name: name, Value: val})
+		}
+	}
+
+	for _, signer := range d.signers {
+		if err := signer.Sign(hreq, buf); err != nil {
+			return nil, NewErrorf(err, "http.SignFailed", "sign request failed, err: [%s]", err.Error())
+		}
+	}
+
+	start := time.Now()
+	hres, err := client.Do(hreq)
 	if err != nil {
-		return nil, errors.Wrap(err, "client.Do failed")
+		return nil, timeoutError(ctx, errors.Wrap(err, "client.Do failed"))
 	}
 	defer hres.Body.Close()
 
+	if d.options.Protocol == "HTTP/2" && hres.ProtoMajor != 2 {
+		return nil, NewErrorf(nil, "http.ProtocolMismatch", "expect HTTP/2, got [%s]", hres.Proto)
+	}
+
 	res := &HttpDoRes{
 		Status: hres.StatusCode,
+		Proto:  hres.Proto,
 	}
 
 	if hres.Header != nil {
@@ -107,12 +373,37 @@ func (d *HttpDriver) Do(req *HttpDoReq) (*HttpDoRes, error) {
 		}
 	}
 
-	buf, err = ioutil.ReadAll(hres.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "ioutil.ReadAll failed")
+	cookies := hres.Cookies()
+	if jar != nil {
+		cookies = jar.Cookies(hreq.URL)
+	}
+	if len(cookies) != 0 {
+		res.Cookies = map[string]string{}
+		for _, cookie := range cookies {
+			res.Cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	if req.Stream != "" {
+		buf, err = readStream(hres.Body, req.Stream, start, res)
+		if err != nil {
+			return nil, timeoutError(ctx, err)
+		}
+	} else {
+		buf, err = ioutil.ReadAll(hres.Body)
+		if err != nil {
+			return nil, timeoutError(ctx, errors.Wrap(err, "ioutil.ReadAll failed"))
+		}
 	}
 
-	if req.JsonDecode {
+	tracer.mutex.Lock()
+	tracer.timing.Download = time.Since(tracer.firstByte)
+	timing := tracer.timing
+	res.ConnReused = tracer.reused
+	tracer.mutex.Unlock()
+	res.Timing = &timing
+
+	if req.JsonDecode && req.Stream == "" {
 		if err := jsoniter.Unmarshal(buf, &res.Json); err != nil {
 			return nil, errors.Wrap(err, "jsoniter.Unmarshal failed")
 		}
@@ -122,3 +413,211 @@ func (d *HttpDriver) Do(req *HttpDoReq) (*HttpDoRes, error) {
 
 	return res, nil
 }
+
+// readStream 按行读取响应，解析出 SSE 的事件或者 NDJSON 的每一行，返回完整的响应
+func readStream(body io.Reader, mode string, start time.Time, res *HttpDoRes) ([]byte, error) {
+	var stat recorder.StreamStat
+	var last time.Time
+	emit := func(event *HttpEvent) {
+		now := time.Now()
+		event.Time = now.Sub(start)
+		if stat.MessageCount == 0 {
+			stat.FirstMessageTime = event.Time
+		} else if gap := now.Sub(last); gap > stat.MaxMessageGap {
+			stat.MaxMessageGap = gap
+		}
+		last = now
+		stat.MessageCount++
+		var v interface{}
+		if err := jsoniter.UnmarshalFromString(event.Data, &v); err == nil {
+			event.Json = v
+		}
+		res.Events = append(res.Events, event)
+	}
+
+	// SSE 事件以空行结束，多个 data 以换行拼接
+	event := &HttpEvent{}
+	var data []string
+	dispatch := func() {
+		if data != nil {
+			event.Data = strings.Join(data, "\n")
+			emit(event)
+		}
+		event = &HttpEvent{}
+		data = nil
+	}
+	process := func(line string) {
+		if mode == "NDJSON" {
+			if strings.TrimSpace(line) != "" {
+				emit(&HttpEvent{Data: line})
+			}
+			return
+		}
+		if line == "" {
+			dispatch()
+			return
+		}
+		if strings.HasPrefix(line, ":") {
+			return
+		}
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			event.Event = value
+		case "id":
+			event.Id = value
+		}
+	}
+
+	var buf bytes.Buffer
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		buf.WriteString(line)
+		if line != "" {
+			process(strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reader.ReadString failed")
+		}
+	}
+	// 最后一个事件没有以空行结束
+	dispatch()
+
+	stat.Duration = time.Since(start)
+	res.Stream = &stat
+	return buf.Bytes(), nil
+}
+
+type httpCookieJarKey struct {
+	driver *HttpDriver
+}
+
+// cookieJar 返回 CookieScope 对应 Session 中的 cookie jar，没有配置 CookieScope 或者不在框架中执行时返回 nil
+func (d *HttpDriver) cookieJar(ctx context.Context) http.CookieJar {
+	var session *Session
+	switch d.options.CookieScope {
+	case "VU":
+		session = VUSession(ctx)
+	case "Unit":
+		session = UnitSession(ctx)
+	}
+	if session == nil {
+		return nil
+	}
+
+	key := httpCookieJarKey{driver: d}
+	if v, ok := session.Get(key); ok {
+		return v.(http.CookieJar)
+	}
+	// cookiejar.New 在 options 为 nil 时不会返回错误
+	jar, _ := cookiejar.New(nil)
+	session.Set(key, jar, nil)
+	return jar
+}
+
+// httpBody 返回请求的 body 和默认的 Content-Type，Headers 中的 Content-Type 优先
+func httpBody(req *HttpDoReq) ([]byte, string, error) {
+	n := 0
+	for _, set := range []bool{req.Data != "" || req.Json != nil, req.Form != nil, req.Multipart != nil, req.Binary != "", req.DataFile != ""} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return nil, "", NewErrorf(nil, "http.InvalidRequest", "only one of Data/Json, Form, Multipart, Binary, DataFile can be set")
+	}
+
+	switch {
+	case req.Data != "":
+		return []byte(req.Data), "", nil
+	case req.Json != nil:
+		buf, err := jsoniter.Marshal(req.Json)
+		if err != nil {
+			return nil, "", errors.WithMessage(err, "jsoniter.Marshal failed")
+		}
+		return buf, "application/json", nil
+	case req.Form != nil:
+		values := url.Values{}
+		for key, val := range req.Form {
+			values.Set(key, val)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	case req.Multipart != nil:
+		return multipartBody(req.Multipart)
+	case req.Binary != "":
+		buf, err := base64.StdEncoding.DecodeString(req.Binary)
+		if err != nil {
+			return nil, "", NewErrorf(err, "http.InvalidRequest", "base64 decode Binary failed, err: [%s]", err.Error())
+		}
+		return buf, "application/octet-stream", nil
+	case req.DataFile != "":
+		buf, err := ioutil.ReadFile(req.DataFile)
+		if err != nil {
+			return nil, "", NewErrorf(err, "http.InvalidRequest", "read DataFile [%s] failed, err: [%s]", req.DataFile, err.Error())
+		}
+		return buf, "application/octet-stream", nil
+	}
+
+	return nil, "", nil
+}
+
+func multipartBody(parts []*HttpPart) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		if part.File == "" && part.Size == 0 {
+			if err := writer.WriteField(part.Name, part.Value); err != nil {
+				return nil, "", errors.Wrap(err, "writer.WriteField failed")
+			}
+			continue
+		}
+
+		var buf []byte
+		filename := part.FileName
+		if part.File != "" {
+			var err error
+			if buf, err = ioutil.ReadFile(part.File); err != nil {
+				return nil, "", NewErrorf(err, "http.InvalidRequest", "read multipart File [%s] failed, err: [%s]", part.File, err.Error())
+			}
+			if filename == "" {
+				filename = filepath.Base(part.File)
+			}
+		} else {
+			buf = bytes.Repeat([]byte{'x'}, part.Size)
+		}
+		if filename == "" {
+			filename = part.Name
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, multipartEscaper.Replace(part.Name), multipartEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "writer.CreatePart failed")
+		}
+		if _, err := w.Write(buf); err != nil {
+			return nil, "", errors.Wrap(err, "write part failed")
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", errors.Wrap(err, "writer.Close failed")
+	}
+
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+var multipartEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
@@ -0,0 +1,499 @@
+package driver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHttpDriver(t *testing.T) {
+	Convey("TestHttpDriver", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"key": "%s"}`, r.URL.Query().Get("key"))
+		}))
+		defer server.Close()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type:    "Http",
+			Options: &HttpDriverOptions{MaxIdleConnsPerHost: 2},
+		})
+		So(err, ShouldBeNil)
+
+		res, err := d.Do(map[string]interface{}{
+			"Method":     "GET",
+			"URL":        server.URL,
+			"Params":     map[string]interface{}{"key": "val"},
+			"JsonDecode": true,
+		})
+		So(err, ShouldBeNil)
+		So(res.(map[string]interface{})["Status"], ShouldEqual, http.StatusOK)
+		So(res.(map[string]interface{})["Json"], ShouldResemble, map[string]interface{}{"key": "val"})
+		So(res.(map[string]interface{})["ConnReused"], ShouldBeFalse)
+		timing := res.(map[string]interface{})["Timing"].(map[string]interface{})
+		So(timing["Connect"], ShouldBeGreaterThan, 0)
+		So(timing["TTFB"], ShouldBeGreaterThan, 0)
+		So(timing["TLSHandshake"], ShouldEqual, 0)
+
+		res, err = d.Do(map[string]interface{}{
+			"Method": "GET",
+			"URL":    server.URL,
+		})
+		So(err, ShouldBeNil)
+		So(res.(map[string]interface{})["ConnReused"], ShouldBeTrue)
+		timing = res.(map[string]interface{})["Timing"].(map[string]interface{})
+		So(timing["Connect"], ShouldEqual, 0)
+		So(timing["TTFB"], ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestHttpDriverBody(t *testing.T) {
+	Convey("TestHttpDriverBody", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := map[string]interface{}{
+				"ContentType":   r.Header.Get("Content-Type"),
+				"ContentLength": r.ContentLength,
+			}
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			switch mediaType {
+			case "application/x-www-form-urlencoded":
+				_ = r.ParseForm()
+				res["Form"] = r.PostForm.Get("key")
+			case "multipart/form-data":
+				_ = r.ParseMultipartForm(1024 * 1024)
+				res["Value"] = r.MultipartForm.Value["key"][0]
+				for name, files := range r.MultipartForm.File {
+					f, _ := files[0].Open()
+					buf, _ := ioutil.ReadAll(f)
+					res[name] = map[string]interface{}{"FileName": files[0].Filename, "Body": string(buf), "ContentType": files[0].Header.Get("Content-Type")}
+				}
+			default:
+				buf, _ := ioutil.ReadAll(r.Body)
+				res["Body"] = base64.StdEncoding.EncodeToString(buf)
+			}
+			buf, _ := jsoniter.Marshal(res)
+			_, _ = w.Write(buf)
+		}))
+		defer server.Close()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{}})
+		So(err, ShouldBeNil)
+
+		dir, err := ioutil.TempDir("", "benv2-http-body")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "data.txt")
+		So(ioutil.WriteFile(filename, []byte("hello world"), 0644), ShouldBeNil)
+
+		do := func(req map[string]interface{}) map[string]interface{} {
+			req["Method"] = "POST"
+			req["URL"] = server.URL
+			req["JsonDecode"] = true
+			res, err := d.Do(req)
+			So(err, ShouldBeNil)
+			return res.(map[string]interface{})["Json"].(map[string]interface{})
+		}
+
+		Convey("json", func() {
+			res := do(map[string]interface{}{"Json": map[string]interface{}{"key": "val"}})
+			So(res["ContentType"], ShouldEqual, "application/json")
+			So(res["Body"], ShouldEqual, base64.StdEncoding.EncodeToString([]byte(`{"key":"val"}`)))
+		})
+
+		Convey("form", func() {
+			res := do(map[string]interface{}{"Form": map[string]interface{}{"key": "hello world"}})
+			So(res["ContentType"], ShouldEqual, "application/x-www-form-urlencoded")
+			So(res["ContentLength"], ShouldEqual, len("key=hello+world"))
+			So(res["Form"], ShouldEqual, "hello world")
+		})
+
+		Convey("multipart", func() {
+			res := do(map[string]interface{}{"Multipart": []interface{}{
+				map[string]interface{}{"Name": "key", "Value": "val"},
+				map[string]interface{}{"Name": "file", "File": filename, "ContentType": "text/plain"},
+				map[string]interface{}{"Name": "generated", "Size": 16, "FileName": "generated.bin"},
+			}})
+			So(res["ContentType"], ShouldStartWith, "multipart/form-data; boundary=")
+			So(res["ContentLength"], ShouldBeGreaterThan, 0)
+			So(res["Value"], ShouldEqual, "val")
+			So(res["file"], ShouldResemble, map[string]interface{}{"FileName": "data.txt", "Body": "hello world", "ContentType": "text/plain"})
+			So(res["generated"], ShouldResemble, map[string]interface{}{"FileName": "generated.bin", "Body": strings.Repeat("x", 16), "ContentType": "application/octet-stream"})
+		})
+
+		Convey("binary", func() {
+			res := do(map[string]interface{}{"Binary": base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 255})})
+			So(res["ContentType"], ShouldEqual, "application/octet-stream")
+			So(res["ContentLength"], ShouldEqual, 4)
+			So(res["Body"], ShouldEqual, "AAEC/w==")
+
+			res = do(map[string]interface{}{"DataFile": filename, "Headers": map[string]interface{}{"Content-Type": "text/plain"}})
+			So(res["ContentType"], ShouldEqual, "text/plain")
+			So(res["ContentLength"], ShouldEqual, 11)
+			So(res["Body"], ShouldEqual, base64.StdEncoding.EncodeToString([]byte("hello world")))
+		})
+
+		Convey("invalid", func() {
+			_, err := d.Do(map[string]interface{}{"Method": "POST", "URL": server.URL, "Binary": "!!", "Form": map[string]interface{}{}})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "http.InvalidRequest")
+
+			_, err = d.Do(map[string]interface{}{"Method": "POST", "URL": server.URL, "Binary": "!!"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "http.InvalidRequest")
+		})
+	})
+}
+
+func TestHttpDriverCookie(t *testing.T) {
+	Convey("TestHttpDriverCookie", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				http.SetCookie(w, &http.Cookie{Name: "token", Value: r.URL.Query().Get("user"), Path: "/"})
+				return
+			}
+			var names []string
+			for _, cookie := range r.Cookies() {
+				names = append(names, cookie.Name+"="+cookie.Value)
+			}
+			sort.Strings(names)
+			_, _ = w.Write([]byte(strings.Join(names, ";")))
+		}))
+		defer server.Close()
+
+		Convey("without cookie scope", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{}})
+			So(err, ShouldBeNil)
+
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL + "/login?user=tom"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Cookies"], ShouldResemble, map[string]interface{}{"token": "tom"})
+
+			res, err = d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL + "/me", "Cookies": map[string]interface{}{"key": "val"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "key=val")
+		})
+
+		Convey("vu cookie scope", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{CookieScope: "VU"}})
+			So(err, ShouldBeNil)
+			cd := d.(ContextDriver)
+
+			session1 := NewSession()
+			defer session1.Close()
+			session2 := NewSession()
+			defer session2.Close()
+			ctx1 := WithVUSession(context.Background(), session1)
+			ctx2 := WithVUSession(context.Background(), session2)
+
+			_, err = cd.DoContext(ctx1, map[string]interface{}{"Method": "GET", "URL": server.URL + "/login?user=tom"})
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx2, map[string]interface{}{"Method": "GET", "URL": server.URL + "/login?user=jerry"})
+			So(err, ShouldBeNil)
+
+			res, err := cd.DoContext(ctx1, map[string]interface{}{"Method": "GET", "URL": server.URL + "/me", "Cookies": map[string]interface{}{"key": "val"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "key=val;token=tom")
+			So(res.(map[string]interface{})["Cookies"], ShouldResemble, map[string]interface{}{"key": "val", "token": "tom"})
+
+			res, err = cd.DoContext(ctx1, map[string]interface{}{"Method": "GET", "URL": server.URL + "/me"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "key=val;token=tom")
+
+			res, err = cd.DoContext(ctx2, map[string]interface{}{"Method": "GET", "URL": server.URL + "/me"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "token=jerry")
+		})
+
+		Convey("unit cookie scope", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{CookieScope: "Unit"}})
+			So(err, ShouldBeNil)
+			cd := d.(ContextDriver)
+
+			session := NewSession()
+			ctx := WithUnitSession(context.Background(), session)
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "GET", "URL": server.URL + "/login?user=tom"})
+			So(err, ShouldBeNil)
+			res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "GET", "URL": server.URL + "/me"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "token=tom")
+			session.Close()
+
+			ctx = WithUnitSession(context.Background(), NewSession())
+			res, err = cd.DoContext(ctx, map[string]interface{}{"Method": "GET", "URL": server.URL + "/me"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "")
+		})
+	})
+}
+
+func TestHttpDriverStream(t *testing.T) {
+	Convey("TestHttpDriverStream", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var chunks []string
+			if r.URL.Path == "/sse" {
+				w.Header().Set("Content-Type", "text/event-stream")
+				chunks = []string{
+					": comment\n\nevent: message\nid: 1\ndata: {\"text\": \"hello\"}\n\n",
+					"data: line1\ndata: line2\n\n",
+					"data: [DONE]\n\n",
+				}
+			} else {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				chunks = []string{"{\"id\": 1}\n", "{\"id\": 2}\n\n", "{\"id\": 3}"}
+			}
+			for i, chunk := range chunks {
+				if i != 0 {
+					time.Sleep(20 * time.Millisecond)
+				}
+				_, _ = w.Write([]byte(chunk))
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer server.Close()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{}})
+		So(err, ShouldBeNil)
+
+		Convey("sse", func() {
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL + "/sse", "Stream": "SSE"})
+			So(err, ShouldBeNil)
+			events := res.(map[string]interface{})["Events"].([]interface{})
+			So(len(events), ShouldEqual, 3)
+			So(events[0].(map[string]interface{})["Event"], ShouldEqual, "message")
+			So(events[0].(map[string]interface{})["Id"], ShouldEqual, "1")
+			So(events[0].(map[string]interface{})["Json"], ShouldResemble, map[string]interface{}{"text": "hello"})
+			So(events[1].(map[string]interface{})["Data"], ShouldEqual, "line1\nline2")
+			So(events[1].(map[string]interface{})["Json"], ShouldBeNil)
+			So(events[2].(map[string]interface{})["Data"], ShouldEqual, "[DONE]")
+
+			stream := res.(map[string]interface{})["Stream"].(map[string]interface{})
+			So(stream["MessageCount"], ShouldEqual, 3)
+			So(stream["FirstMessageTime"], ShouldBeGreaterThan, 0)
+			So(stream["MaxMessageGap"], ShouldBeGreaterThanOrEqualTo, int64(15*time.Millisecond))
+			So(stream["Duration"], ShouldBeGreaterThanOrEqualTo, int64(40*time.Millisecond))
+			So(res.(map[string]interface{})["Text"], ShouldStartWith, ": comment")
+		})
+
+		Convey("ndjson", func() {
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL + "/ndjson", "Stream": "NDJSON"})
+			So(err, ShouldBeNil)
+			events := res.(map[string]interface{})["Events"].([]interface{})
+			So(len(events), ShouldEqual, 3)
+			for i, event := range events {
+				So(event.(map[string]interface{})["Json"], ShouldResemble, map[string]interface{}{"id": int64(i + 1)})
+			}
+			So(events[2].(map[string]interface{})["Time"], ShouldBeGreaterThan, events[0].(map[string]interface{})["Time"])
+		})
+
+		Convey("unknown stream", func() {
+			_, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL, "Stream": "XML"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "http.InvalidRequest")
+		})
+	})
+}
+
+func TestHttpDriverContext(t *testing.T) {
+	Convey("TestHttpDriverContext", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			_, _ = w.Write([]byte(r.Header.Get("X-Trace-Id")))
+		}))
+		defer server.Close()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{TraceHeader: "X-Trace-Id"}})
+		So(err, ShouldBeNil)
+
+		Convey("trace header", func() {
+			ctx := WithTrace(context.Background(), &Trace{ID: "abc", Unit: "unit1"})
+			res, err := d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "abc")
+
+			res, err = d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "")
+		})
+
+		Convey("request timeout", func() {
+			_, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL + "/slow", "Timeout": "50ms"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+		})
+
+		Convey("context canceled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Method": "GET", "URL": server.URL + "/slow"})
+			So(err, ShouldNotBeNil)
+			_, ok := errors.Cause(err).(*Error)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+// generateClientCert 生成自签名的客户端证书，返回证书和私钥的 pem 文件
+func generateClientCert(dir string) (*x509.Certificate, string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "benv2"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, "", "", err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, "", "", err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, "", "", err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return nil, "", "", err
+	}
+	return cert, certFile, keyFile, nil
+}
+
+func TestHttpDriverTLS(t *testing.T) {
+	Convey("TestHttpDriverTLS", t, func() {
+		dir, err := ioutil.TempDir("", "benv2-http-tls")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		caFile := filepath.Join(dir, "ca.crt")
+		So(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644), ShouldBeNil)
+
+		newDriver := func(options *HttpDriverOptions) Driver {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: options})
+			So(err, ShouldBeNil)
+			return d
+		}
+
+		Convey("unknown authority", func() {
+			d := newDriver(&HttpDriverOptions{})
+			_, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("insecure skip verify", func() {
+			d := newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{InsecureSkipVerify: true}})
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "HTTP/2.0")
+			So(res.(map[string]interface{})["Timing"].(map[string]interface{})["TLSHandshake"], ShouldBeGreaterThan, 0)
+		})
+
+		Convey("ca and server name", func() {
+			d := newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{CAFile: caFile, MinVersion: "1.2"}})
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Proto"], ShouldEqual, "HTTP/2.0")
+
+			// httptest 的证书包含 example.com
+			d = newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{CAFile: caFile, ServerName: "example.com"}})
+			_, err = d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+
+			d = newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{CAFile: caFile, ServerName: "example.org"}})
+			_, err = d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("protocol", func() {
+			d := newDriver(&HttpDriverOptions{Protocol: "HTTP/1.1", TLS: HttpTLSOptions{CAFile: caFile}})
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Proto"], ShouldEqual, "HTTP/1.1")
+
+			plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer plain.Close()
+			d = newDriver(&HttpDriverOptions{Protocol: "HTTP/2"})
+			_, err = d.Do(map[string]interface{}{"Method": "GET", "URL": plain.URL})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "http.ProtocolMismatch")
+
+			_, err = NewDriverWithOptions(&refx.TypeOptions{Type: "Http", Options: &HttpDriverOptions{Protocol: "HTTP/3"}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("disable keep alives", func() {
+			d := newDriver(&HttpDriverOptions{DisableKeepAlives: true, TLS: HttpTLSOptions{CAFile: caFile}})
+			for i := 0; i < 2; i++ {
+				res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": server.URL})
+				So(err, ShouldBeNil)
+				So(res.(map[string]interface{})["ConnReused"], ShouldBeFalse)
+			}
+		})
+
+		Convey("client certificate", func() {
+			cert, certFile, keyFile, err := generateClientCert(dir)
+			So(err, ShouldBeNil)
+			pool := x509.NewCertPool()
+			pool.AddCert(cert)
+
+			mtls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+			}))
+			mtls.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+			mtls.StartTLS()
+			defer mtls.Close()
+
+			mtlsCAFile := filepath.Join(dir, "mtls-ca.crt")
+			So(ioutil.WriteFile(mtlsCAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mtls.Certificate().Raw}), 0644), ShouldBeNil)
+
+			d := newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{CAFile: mtlsCAFile}})
+			_, err = d.Do(map[string]interface{}{"Method": "GET", "URL": mtls.URL})
+			So(err, ShouldNotBeNil)
+
+			d = newDriver(&HttpDriverOptions{TLS: HttpTLSOptions{CAFile: mtlsCAFile, CertFile: certFile, KeyFile: keyFile}})
+			res, err := d.Do(map[string]interface{}{"Method": "GET", "URL": mtls.URL})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Text"], ShouldEqual, "benv2")
+		})
+	})
+}
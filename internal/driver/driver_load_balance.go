@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+)
+
+type LoadBalanceEndpoint struct {
+	// 统计中 endpoint 的名字，为空时使用序号
+	Name string
+	// Weighted 策略的权重
+	Weight int
+	Driver refx.TypeOptions
+}
+
+type LoadBalanceDriverOptions struct {
+	// RoundRobin，Random，Weighted，Sticky，Sticky 时每个 VU 固定使用一个 endpoint，不在 VU 中执行时和 RoundRobin 一样
+	Strategy  string `dft:"RoundRobin"`
+	Endpoints []LoadBalanceEndpoint
+}
+
+func NewLoadBalanceDriverWithOptions(options *LoadBalanceDriverOptions, opts ...refx.Option) (*LoadBalanceDriver, error) {
+	switch options.Strategy {
+	case "":
+		options.Strategy = "RoundRobin"
+	case "RoundRobin", "Random", "Weighted", "Sticky":
+	default:
+		return nil, errors.Errorf("unsupported strategy [%s]", options.Strategy)
+	}
+	if len(options.Endpoints) == 0 {
+		return nil, errors.New("Endpoints is required")
+	}
+
+	d := &LoadBalanceDriver{options: options}
+	names := map[string]bool{}
+	total := 0
+	for i := range options.Endpoints {
+		endpoint := &options.Endpoints[i]
+		if endpoint.Name == "" {
+			endpoint.Name = fmt.Sprintf("%d", i)
+		}
+		if names[endpoint.Name] {
+			return nil, errors.Errorf("duplicated endpoint name [%s]", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+		if endpoint.Weight < 0 {
+			return nil, errors.Errorf("weight of endpoint [%s] should not be negative", endpoint.Name)
+		}
+		total += endpoint.Weight
+		d.bound = append(d.bound, total)
+	}
+	if options.Strategy == "Weighted" && total == 0 {
+		return nil, errors.New("Weighted strategy should have at least one positive weight")
+	}
+
+	for _, endpoint := range options.Endpoints {
+		driver, err := NewDriverWithOptions(&endpoint.Driver, opts...)
+		if err != nil {
+			_ = d.Close()
+			return nil, errors.WithMessagef(err, "NewDriverWithOptions failed, endpoint: [%s]", endpoint.Name)
+		}
+		d.drivers = append(d.drivers, driver)
+	}
+
+	return d, nil
+}
+
+// LoadBalanceDriver 将请求分发到多个 endpoint，如同一个服务的多个副本，处理请求的 endpoint 记录在 StepStat 中
+type LoadBalanceDriver struct {
+	options *LoadBalanceDriverOptions
+	drivers []Driver
+	// Weighted 策略的累计权重
+	bound []int
+	next  uint64
+}
+
+func (d *LoadBalanceDriver) Do(req interface{}) (interface{}, error) {
+	return d.DoContext(context.Background(), req)
+}
+
+func (d *LoadBalanceDriver) DoContext(ctx context.Context, req interface{}) (interface{}, error) {
+	idx := d.pick(ctx)
+	if endpoint, ok := ctx.Value(endpointKey{}).(*string); ok {
+		*endpoint = d.options.Endpoints[idx].Name
+	}
+	if cd, ok := d.drivers[idx].(ContextDriver); ok {
+		return cd.DoContext(ctx, req)
+	}
+	return d.drivers[idx].Do(req)
+}
+
+// Close 关闭所有 endpoint 的驱动
+func (d *LoadBalanceDriver) Close() error {
+	var err error
+	for i, driver := range d.drivers {
+		if e := Close(driver); e != nil && err == nil {
+			err = errors.WithMessagef(e, "close endpoint [%s] failed", d.options.Endpoints[i].Name)
+		}
+	}
+	return err
+}
+
+func (d *LoadBalanceDriver) pick(ctx context.Context) int {
+	switch d.options.Strategy {
+	case "Random":
+		return rand.Intn(len(d.drivers))
+	case "Weighted":
+		n := rand.Intn(d.bound[len(d.bound)-1])
+		return sort.SearchInts(d.bound, n+1)
+	case "Sticky":
+		if session := VUSession(ctx); session != nil {
+			if v, ok := session.Get(d); ok {
+				return v.(int)
+			}
+			idx := d.roundRobin()
+			session.Set(d, idx, nil)
+			return idx
+		}
+	}
+	return d.roundRobin()
+}
+
+func (d *LoadBalanceDriver) roundRobin() int {
+	return int((atomic.AddUint64(&d.next, 1) - 1) % uint64(len(d.drivers)))
+}
+
+type endpointKey struct{}
+
+// WithEndpoint 返回的 ctx 用于执行请求时，负载均衡驱动将处理请求的 endpoint 名字写入返回的指针
+func WithEndpoint(ctx context.Context) (context.Context, *string) {
+	var endpoint string
+	return context.WithValue(ctx, endpointKey{}, &endpoint), &endpoint
+}
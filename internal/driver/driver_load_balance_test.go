@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hatlonely/go-kit/refx"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoadBalanceDriver(t *testing.T) {
+	Convey("TestLoadBalanceDriver", t, func() {
+		newDriver := func(strategy string, weights ...int) ContextDriver {
+			var endpoints []LoadBalanceEndpoint
+			for i, name := range []string{"a", "b", "c"} {
+				endpoint := LoadBalanceEndpoint{
+					Name:   name,
+					Driver: refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{Res: map[string]interface{}{"Name": name}}},
+				}
+				if len(weights) != 0 {
+					endpoint.Weight = weights[i]
+				}
+				endpoints = append(endpoints, endpoint)
+			}
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "LoadBalance", Options: &LoadBalanceDriverOptions{
+				Strategy:  strategy,
+				Endpoints: endpoints,
+			}})
+			So(err, ShouldBeNil)
+			return d.(ContextDriver)
+		}
+		do := func(d ContextDriver, ctx context.Context) string {
+			ctx, endpoint := WithEndpoint(ctx)
+			res, err := d.DoContext(ctx, map[string]interface{}{})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Name"], ShouldEqual, *endpoint)
+			return *endpoint
+		}
+
+		Convey("round robin", func() {
+			d := newDriver("RoundRobin")
+			var names []string
+			for i := 0; i < 6; i++ {
+				names = append(names, do(d, context.Background()))
+			}
+			So(names, ShouldResemble, []string{"a", "b", "c", "a", "b", "c"})
+		})
+
+		Convey("random", func() {
+			d := newDriver("Random")
+			counts := map[string]int{}
+			for i := 0; i < 3000; i++ {
+				counts[do(d, context.Background())]++
+			}
+			So(counts["a"], ShouldAlmostEqual, 1000, 200)
+			So(counts["b"], ShouldAlmostEqual, 1000, 200)
+			So(counts["c"], ShouldAlmostEqual, 1000, 200)
+		})
+
+		Convey("weighted", func() {
+			d := newDriver("Weighted", 7, 3, 0)
+			counts := map[string]int{}
+			for i := 0; i < 10000; i++ {
+				counts[do(d, context.Background())]++
+			}
+			So(counts["a"], ShouldAlmostEqual, 7000, 300)
+			So(counts["b"], ShouldAlmostEqual, 3000, 300)
+			So(counts["c"], ShouldEqual, 0)
+		})
+
+		Convey("sticky", func() {
+			d := newDriver("Sticky")
+			session1 := NewSession()
+			defer session1.Close()
+			session2 := NewSession()
+			defer session2.Close()
+			ctx1 := WithVUSession(context.Background(), session1)
+			ctx2 := WithVUSession(context.Background(), session2)
+
+			name1, name2 := do(d, ctx1), do(d, ctx2)
+			So(name1, ShouldNotEqual, name2)
+			for i := 0; i < 5; i++ {
+				So(do(d, ctx1), ShouldEqual, name1)
+				So(do(d, ctx2), ShouldEqual, name2)
+			}
+		})
+
+		Convey("close", func() {
+			defer os.RemoveAll("load_balance.log")
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "LoadBalance", Options: &LoadBalanceDriverOptions{
+				Endpoints: []LoadBalanceEndpoint{{Driver: refx.TypeOptions{Type: "Middleware", Options: &MiddlewareDriverOptions{
+					Driver:     refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{}},
+					Middleware: []refx.TypeOptions{{Type: "Log", Options: &LogMiddlewareOptions{FilePath: "load_balance.log"}}},
+				}}}},
+			}})
+			So(err, ShouldBeNil)
+			m := d.(*LoadBalanceDriver).drivers[0].(*MiddlewareDriver).middlewares[0].(*LogMiddleware)
+			So(Close(d), ShouldBeNil)
+			_, err = m.fp.WriteString("\n")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("invalid options", func() {
+			mock := refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{}}
+			for _, options := range []*LoadBalanceDriverOptions{
+				{},
+				{Strategy: "LeastConn", Endpoints: []LoadBalanceEndpoint{{Driver: mock}}},
+				{Strategy: "Weighted", Endpoints: []LoadBalanceEndpoint{{Driver: mock}}},
+				{Endpoints: []LoadBalanceEndpoint{{Name: "a", Driver: mock}, {Name: "a", Driver: mock}}},
+				{Endpoints: []LoadBalanceEndpoint{{Driver: refx.TypeOptions{Type: "Unknown"}}}},
+			} {
+				_, err := NewDriverWithOptions(&refx.TypeOptions{Type: "LoadBalance", Options: options})
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
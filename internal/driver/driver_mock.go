@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hatlonely/benv2/internal/eval"
+	"github.com/pkg/errors"
+)
+
+type MockLatencyOptions struct {
+	// Constant，Uniform，Normal，LogNormal，Bimodal
+	Distribution string `dft:"Constant"`
+	// Constant 的时长，Normal，LogNormal 和 Bimodal 第一个峰的均值
+	Mean time.Duration
+	// Normal，LogNormal 和 Bimodal 第一个峰的标准差
+	Stddev time.Duration
+	// Uniform 的范围 [Min, Max)
+	Min time.Duration
+	Max time.Duration
+	// Bimodal 第二个峰的均值和标准差，SlowRatio 为落在第二个峰的概率，如缓存未命中的请求
+	SlowMean   time.Duration
+	SlowStddev time.Duration
+	SlowRatio  float64
+}
+
+type MockError struct {
+	Code    string
+	Message string
+	// 返回该错误的概率，所有错误的概率之和不超过 1
+	Rate float64
+}
+
+type MockDriverOptions struct {
+	Latency MockLatencyOptions
+	// 响应模板，`#` 开头的 key 为表达式，可以通过 req 引用请求，如 {"#Echo": "req.Message"}，为空时原样返回请求
+	Res    interface{}
+	Errors []MockError
+}
+
+func NewMockDriverWithOptions(options *MockDriverOptions) (*MockDriver, error) {
+	latency := &options.Latency
+	switch latency.Distribution {
+	case "":
+		latency.Distribution = "Constant"
+	case "Constant", "Normal", "LogNormal":
+	case "Uniform":
+		if latency.Max < latency.Min {
+			return nil, errors.Errorf("Max [%v] should not be less than Min [%v]", latency.Max, latency.Min)
+		}
+	case "Bimodal":
+		if latency.SlowRatio < 0 || latency.SlowRatio > 1 {
+			return nil, errors.Errorf("SlowRatio [%v] should be in [0, 1]", latency.SlowRatio)
+		}
+	default:
+		return nil, errors.Errorf("unsupported distribution [%s]", latency.Distribution)
+	}
+
+	total := 0.0
+	for _, e := range options.Errors {
+		if e.Code == "" {
+			return nil, errors.New("error Code is required")
+		}
+		total += e.Rate
+	}
+	if total > 1 {
+		return nil, errors.Errorf("sum of error rates [%v] should not be greater than 1", total)
+	}
+
+	d := &MockDriver{options: options}
+	if options.Res != nil {
+		res, err := eval.NewEvaluable(options.Res)
+		if err != nil {
+			return nil, errors.WithMessage(err, "eval.NewEvaluable failed")
+		}
+		d.res = res
+	}
+
+	return d, nil
+}
+
+// MockDriver 不访问任何服务，按照配置的分布等待后返回模板生成的响应，用于离线调试 playbook，报告和统计
+type MockDriver struct {
+	options *MockDriverOptions
+	res     *eval.Evaluable
+}
+
+func (d *MockDriver) Do(req interface{}) (interface{}, error) {
+	return d.DoContext(context.Background(), req)
+}
+
+func (d *MockDriver) DoContext(ctx context.Context, req interface{}) (interface{}, error) {
+	if err := sleepContext(ctx, d.latency()); err != nil {
+		return nil, errors.Wrap(err, "wait for latency failed")
+	}
+
+	n := rand.Float64()
+	for _, e := range d.options.Errors {
+		if n < e.Rate {
+			message := e.Message
+			if message == "" {
+				message = "mock error"
+			}
+			return nil, NewError(nil, e.Code, message)
+		}
+		n -= e.Rate
+	}
+
+	if d.res == nil {
+		return req, nil
+	}
+	res, err := d.res.Evaluate(map[string]interface{}{"req": req})
+	if err != nil {
+		return nil, NewErrorf(err, "mock.InvalidTemplate", "evaluate Res failed, err: [%s]", err.Error())
+	}
+	return res, nil
+}
+
+// latency 按照配置的分布生成响应时间，小于 0 时为 0
+func (d *MockDriver) latency() time.Duration {
+	o := &d.options.Latency
+	var v float64
+	switch o.Distribution {
+	case "Uniform":
+		v = float64(o.Min)
+		if o.Max > o.Min {
+			v += float64(rand.Int63n(int64(o.Max - o.Min)))
+		}
+	case "Normal":
+		v = float64(o.Mean) + rand.NormFloat64()*float64(o.Stddev)
+	case "LogNormal":
+		// 由目标分布的均值和标准差计算对数的均值和标准差
+		if o.Mean > 0 {
+			sigma2 := math.Log(1 + math.Pow(float64(o.Stddev)/float64(o.Mean), 2))
+			mu := math.Log(float64(o.Mean)) - sigma2/2
+			v = math.Exp(mu + rand.NormFloat64()*math.Sqrt(sigma2))
+		}
+	case "Bimodal":
+		if rand.Float64() < o.SlowRatio {
+			v = float64(o.SlowMean) + rand.NormFloat64()*float64(o.SlowStddev)
+		} else {
+			v = float64(o.Mean) + rand.NormFloat64()*float64(o.Stddev)
+		}
+	default:
+		v = float64(o.Mean)
+	}
+	if v < 0 {
+		return 0
+	}
+	return time.Duration(v)
+}
@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMockDriver(t *testing.T) {
+	Convey("TestMockDriver", t, func() {
+		Convey("echo", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{
+				Latency: MockLatencyOptions{Mean: 20 * time.Millisecond},
+			}})
+			So(err, ShouldBeNil)
+			now := time.Now()
+			res, err := d.Do(map[string]interface{}{"key": "val"})
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, map[string]interface{}{"key": "val"})
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+			defer cancel()
+			_, err = d.(ContextDriver).DoContext(ctx, map[string]interface{}{})
+			So(errors.Cause(err), ShouldResemble, context.DeadlineExceeded)
+		})
+
+		Convey("template", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{
+				Res: map[string]interface{}{
+					"Status": 200,
+					"Body": map[string]interface{}{
+						"#Echo": "req.Message",
+						"#Len":  "len(req.Message)",
+					},
+				},
+			}})
+			So(err, ShouldBeNil)
+			res, err := d.Do(map[string]interface{}{"Message": "hello"})
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, map[string]interface{}{
+				"Status": 200,
+				"Body":   map[string]interface{}{"Echo": "hello", "Len": 5},
+			})
+		})
+
+		Convey("errors", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &MockDriverOptions{
+				Errors: []MockError{{Code: "Throttling", Rate: 0.2}, {Code: "InternalError", Message: "internal error", Rate: 0.1}},
+			}})
+			So(err, ShouldBeNil)
+			codes := map[string]int{}
+			for i := 0; i < 10000; i++ {
+				_, err := d.Do(map[string]interface{}{})
+				if err != nil {
+					codes[errors.Cause(err).(*Error).Code]++
+				} else {
+					codes[""]++
+				}
+			}
+			So(codes["Throttling"], ShouldAlmostEqual, 2000, 300)
+			So(codes["InternalError"], ShouldAlmostEqual, 1000, 300)
+			So(codes[""], ShouldAlmostEqual, 7000, 300)
+		})
+
+		Convey("latency", func() {
+			sample := func(options MockLatencyOptions) (float64, float64, []time.Duration) {
+				d, err := NewMockDriverWithOptions(&MockDriverOptions{Latency: options})
+				So(err, ShouldBeNil)
+				var vs []time.Duration
+				var sum, sum2 float64
+				for i := 0; i < 20000; i++ {
+					v := d.latency()
+					vs = append(vs, v)
+					sum += float64(v)
+					sum2 += float64(v) * float64(v)
+				}
+				sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+				mean := sum / float64(len(vs))
+				return mean, math.Sqrt(sum2/float64(len(vs)) - mean*mean), vs
+			}
+
+			mean, stddev, _ := sample(MockLatencyOptions{Mean: 10 * time.Millisecond})
+			So(mean, ShouldEqual, float64(10*time.Millisecond))
+			So(stddev, ShouldAlmostEqual, 0, float64(time.Microsecond))
+
+			mean, _, vs := sample(MockLatencyOptions{Distribution: "Uniform", Min: 10 * time.Millisecond, Max: 20 * time.Millisecond})
+			So(mean, ShouldAlmostEqual, float64(15*time.Millisecond), float64(200*time.Microsecond))
+			So(vs[0], ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+			So(vs[len(vs)-1], ShouldBeLessThan, 20*time.Millisecond)
+
+			mean, stddev, _ = sample(MockLatencyOptions{Distribution: "Normal", Mean: 100 * time.Millisecond, Stddev: 10 * time.Millisecond})
+			So(mean, ShouldAlmostEqual, float64(100*time.Millisecond), float64(time.Millisecond))
+			So(stddev, ShouldAlmostEqual, float64(10*time.Millisecond), float64(time.Millisecond))
+
+			mean, stddev, vs = sample(MockLatencyOptions{Distribution: "LogNormal", Mean: 100 * time.Millisecond, Stddev: 50 * time.Millisecond})
+			So(mean, ShouldAlmostEqual, float64(100*time.Millisecond), float64(3*time.Millisecond))
+			So(stddev, ShouldAlmostEqual, float64(50*time.Millisecond), float64(5*time.Millisecond))
+			// 长尾分布的中位数小于均值
+			So(vs[len(vs)/2], ShouldBeLessThan, 100*time.Millisecond)
+
+			_, _, vs = sample(MockLatencyOptions{Distribution: "Bimodal", Mean: 10 * time.Millisecond, Stddev: time.Millisecond, SlowMean: 100 * time.Millisecond, SlowStddev: 5 * time.Millisecond, SlowRatio: 0.1})
+			So(vs[len(vs)/2], ShouldAlmostEqual, 10*time.Millisecond, time.Millisecond)
+			So(vs[len(vs)*95/100], ShouldAlmostEqual, 100*time.Millisecond, 10*time.Millisecond)
+		})
+
+		Convey("invalid options", func() {
+			for _, options := range []*MockDriverOptions{
+				{Latency: MockLatencyOptions{Distribution: "Poisson"}},
+				{Latency: MockLatencyOptions{Distribution: "Uniform", Min: time.Second}},
+				{Latency: MockLatencyOptions{Distribution: "Bimodal", SlowRatio: 2}},
+				{Errors: []MockError{{Code: "A", Rate: 0.6}, {Code: "B", Rate: 0.6}}},
+				{Errors: []MockError{{Rate: 0.1}}},
+			} {
+				_, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: options})
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
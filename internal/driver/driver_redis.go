@@ -0,0 +1,339 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+type RedisDriverOptions struct {
+	Addr         string `dft:"127.0.0.1:6379"`
+	Password     string
+	DB           int
+	PoolSize     int           `dft:"20"`
+	DialTimeout  time.Duration `dft:"3s"`
+	ReadTimeout  time.Duration `dft:"3s"`
+	WriteTimeout time.Duration `dft:"3s"`
+}
+
+func NewRedisDriverWithOptions(options *RedisDriverOptions) (*RedisDriver, error) {
+	if options.PoolSize <= 0 {
+		return nil, errors.Errorf("PoolSize should be positive. PoolSize: [%v]", options.PoolSize)
+	}
+
+	d := &RedisDriver{
+		options: options,
+		sem:     make(chan struct{}, options.PoolSize),
+		idle:    make(chan *redisConn, options.PoolSize),
+	}
+
+	// 提前建立一个连接，尽早发现地址或者密码错误
+	conn, err := d.dial()
+	if err != nil {
+		return nil, errors.WithMessage(err, "d.dial failed")
+	}
+	d.idle <- conn
+
+	return d, nil
+}
+
+type RedisDriver struct {
+	options *RedisDriverOptions
+
+	// sem 限制连接总数，idle 保存空闲连接
+	sem  chan struct{}
+	idle chan *redisConn
+}
+
+type RedisCommand struct {
+	Command string
+	Args    []interface{}
+}
+
+type RedisDoReq struct {
+	Command string
+	Args    []interface{}
+	// 流水线，多个命令一次发送，不为空时忽略 Command
+	Pipeline []*RedisCommand
+}
+
+type RedisDoRes struct {
+	// 简单字符串和批量字符串为 string，整数为 int64，数组为 list，空值为 nil
+	Res interface{}
+	// 流水线中每个命令的返回
+	Ress []interface{}
+}
+
+func (d *RedisDriver) Do(ctx context.Context, req *RedisDoReq) (*RedisDoRes, error) {
+	cmds := req.Pipeline
+	if len(cmds) == 0 {
+		if req.Command == "" {
+			return nil, NewErrorf(nil, "redis.InvalidRequest", "Command is required")
+		}
+		cmds = []*RedisCommand{{Command: req.Command, Args: req.Args}}
+	}
+
+	conn, err := d.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.do(ctx, cmds, d.options.WriteTimeout, d.options.ReadTimeout)
+	// 网络错误后连接中可能残留未读取的数据，不能再复用
+	d.put(conn, err != nil)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+
+	for _, reply := range replies {
+		if e, ok := reply.(redisError); ok {
+			return nil, e.toError()
+		}
+	}
+
+	if len(req.Pipeline) != 0 {
+		return &RedisDoRes{Ress: replies}, nil
+	}
+	return &RedisDoRes{Res: replies[0]}, nil
+}
+
+// Close 关闭连接池中空闲的连接
+func (d *RedisDriver) Close() error {
+	for {
+		select {
+		case conn := <-d.idle:
+			_ = conn.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (d *RedisDriver) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case d.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "wait for redis connection failed")
+	}
+
+	select {
+	case conn := <-d.idle:
+		return conn, nil
+	default:
+	}
+
+	conn, err := d.dial()
+	if err != nil {
+		<-d.sem
+		return nil, errors.WithMessage(err, "d.dial failed")
+	}
+	return conn, nil
+}
+
+func (d *RedisDriver) put(conn *redisConn, broken bool) {
+	if broken {
+		_ = conn.conn.Close()
+	} else {
+		d.idle <- conn
+	}
+	<-d.sem
+}
+
+func (d *RedisDriver) dial() (*redisConn, error) {
+	c, err := net.DialTimeout("tcp", d.options.Addr, d.options.DialTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "net.DialTimeout [%s] failed", d.options.Addr)
+	}
+	conn := &redisConn{conn: c, reader: bufio.NewReader(c)}
+
+	var cmds []*RedisCommand
+	if d.options.Password != "" {
+		cmds = append(cmds, &RedisCommand{Command: "AUTH", Args: []interface{}{d.options.Password}})
+	}
+	if d.options.DB != 0 {
+		cmds = append(cmds, &RedisCommand{Command: "SELECT", Args: []interface{}{d.options.DB}})
+	}
+	if len(cmds) == 0 {
+		return conn, nil
+	}
+
+	replies, err := conn.do(context.Background(), cmds, d.options.WriteTimeout, d.options.ReadTimeout)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	for _, reply := range replies {
+		if e, ok := reply.(redisError); ok {
+			_ = c.Close()
+			return nil, e.toError()
+		}
+	}
+
+	return conn, nil
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *redisConn) do(ctx context.Context, cmds []*RedisCommand, writeTimeout, readTimeout time.Duration) ([]interface{}, error) {
+	// ctx 取消时让阻塞的读写立即返回，返回前等待协程退出，避免连接归还后被其他请求使用时设置超时
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	defer func() {
+		close(done)
+		<-exited
+	}()
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = c.conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	var buf []byte
+	for _, cmd := range cmds {
+		var err error
+		buf, err = appendCommand(buf, cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.conn.SetWriteDeadline(deadline(ctx, writeTimeout)); err != nil {
+		return nil, errors.Wrap(err, "conn.SetWriteDeadline failed")
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, errors.Wrap(err, "conn.Write failed")
+	}
+
+	if err := c.conn.SetReadDeadline(deadline(ctx, readTimeout)); err != nil {
+		return nil, errors.Wrap(err, "conn.SetReadDeadline failed")
+	}
+	replies := make([]interface{}, 0, len(cmds))
+	for range cmds {
+		reply, err := readRedisReply(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+
+	return replies, nil
+}
+
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout != 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+	return t
+}
+
+// appendCommand 将命令编码成 RESP 数组
+func appendCommand(buf []byte, cmd *RedisCommand) ([]byte, error) {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(cmd.Args)+1), 10)
+	buf = append(buf, "\r\n"...)
+	buf = appendBulkString(buf, cmd.Command)
+	for _, arg := range cmd.Args {
+		str, err := cast.ToStringE(arg)
+		if err != nil {
+			return nil, NewErrorf(err, "redis.InvalidRequest", "invalid arg [%v] of command [%s]", arg, cmd.Command)
+		}
+		buf = appendBulkString(buf, str)
+	}
+	return buf, nil
+}
+
+func appendBulkString(buf []byte, str string) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(str)), 10)
+	buf = append(buf, "\r\n"...)
+	buf = append(buf, str...)
+	return append(buf, "\r\n"...)
+}
+
+// redisError 为 redis 返回的错误，如 "WRONGTYPE Operation against a key holding the wrong kind of value"
+type redisError string
+
+// toError 以错误的第一个单词作为错误码，如 ERR，WRONGTYPE，NOAUTH
+func (e redisError) toError() error {
+	code := string(e)
+	if idx := strings.IndexByte(code, ' '); idx != -1 {
+		code = code[:idx]
+	}
+	return NewError(nil, code, string(e))
+}
+
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "reader.ReadString failed")
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("invalid redis reply: empty line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redis integer reply [%s]", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redis bulk string reply [%s]", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, errors.Wrap(err, "io.ReadFull failed")
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redis array reply [%s]", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vs := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := readRedisReply(reader)
+			if err != nil {
+				return nil, err
+			}
+			// 数组中的错误作为普通字符串返回
+			if e, ok := v.(redisError); ok {
+				v = string(e)
+			}
+			vs = append(vs, v)
+		}
+		return vs, nil
+	}
+
+	return nil, errors.Errorf("invalid redis reply [%s]", line)
+}
@@ -0,0 +1,243 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// redisStandIn 只实现了测试用到的几个命令
+type redisStandIn struct {
+	password string
+	mutex    sync.Mutex
+	strs     map[string]string
+	lists    map[string][]string
+}
+
+func (s *redisStandIn) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *redisStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		v, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range v.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		cmd := strings.ToUpper(args[0])
+		if cmd == "AUTH" {
+			authed = len(args) == 2 && args[1] == s.password
+			if !authed {
+				_, _ = conn.Write([]byte("-WRONGPASS invalid username-password pair\r\n"))
+				continue
+			}
+			_, _ = conn.Write([]byte("+OK\r\n"))
+			continue
+		}
+		if !authed {
+			_, _ = conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			continue
+		}
+		_, _ = conn.Write([]byte(s.exec(cmd, args[1:])))
+	}
+}
+
+func (s *redisStandIn) exec(cmd string, args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		s.strs[args[0]] = args[1]
+		return "+OK\r\n"
+	case "GET":
+		if _, ok := s.lists[args[0]]; ok {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		v, ok := s.strs[args[0]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "INCR":
+		v, ok := s.strs[args[0]]
+		if !ok {
+			v = "0"
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		s.strs[args[0]] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "RPUSH":
+		s.lists[args[0]] = append(s.lists[args[0]], args[1:]...)
+		return fmt.Sprintf(":%d\r\n", len(s.lists[args[0]]))
+	case "LRANGE":
+		var buf strings.Builder
+		buf.WriteString(fmt.Sprintf("*%d\r\n", len(s.lists[args[0]])))
+		for _, v := range s.lists[args[0]] {
+			buf.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v))
+		}
+		return buf.String()
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd)
+}
+
+func TestRedisDriver(t *testing.T) {
+	Convey("TestRedisDriver", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+		go (&redisStandIn{
+			password: "123456",
+			strs:     map[string]string{},
+			lists:    map[string][]string{},
+		}).serve(listener)
+
+		Convey("auth failed", func() {
+			_, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type: "Redis",
+				Options: &RedisDriverOptions{
+					Addr:     listener.Addr().String(),
+					Password: "wrong",
+					PoolSize: 2,
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "WRONGPASS")
+		})
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "Redis",
+			Options: &RedisDriverOptions{
+				Addr:     listener.Addr().String(),
+				Password: "123456",
+				PoolSize: 2,
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("command", func() {
+			res, err := d.Do(map[string]interface{}{"Command": "PING"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, "PONG")
+
+			res, err = d.Do(map[string]interface{}{"Command": "SET", "Args": []interface{}{"key1", "val1"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, "OK")
+
+			res, err = d.Do(map[string]interface{}{"Command": "GET", "Args": []interface{}{"key1"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, "val1")
+
+			res, err = d.Do(map[string]interface{}{"Command": "GET", "Args": []interface{}{"key2"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldBeNil)
+
+			res, err = d.Do(map[string]interface{}{"Command": "INCR", "Args": []interface{}{"counter"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, int64(1))
+
+			res, err = d.Do(map[string]interface{}{"Command": "RPUSH", "Args": []interface{}{"list", "a", 2}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, int64(2))
+
+			res, err = d.Do(map[string]interface{}{"Command": "LRANGE", "Args": []interface{}{"list", 0, -1}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldResemble, []interface{}{"a", "2"})
+		})
+
+		Convey("error reply", func() {
+			_, err := d.Do(map[string]interface{}{"Command": "SET", "Args": []interface{}{"key1", "val1"}})
+			So(err, ShouldBeNil)
+			_, err = d.Do(map[string]interface{}{"Command": "INCR", "Args": []interface{}{"key1"}})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "ERR")
+
+			_, err = d.Do(map[string]interface{}{"Command": "RPUSH", "Args": []interface{}{"list", "a"}})
+			So(err, ShouldBeNil)
+			_, err = d.Do(map[string]interface{}{"Command": "GET", "Args": []interface{}{"list"}})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "WRONGTYPE")
+
+			_, err = d.Do(map[string]interface{}{"Command": "UNKNOWN"})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "ERR")
+		})
+
+		Convey("pipeline", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Pipeline": []interface{}{
+					map[string]interface{}{"Command": "SET", "Args": []interface{}{"key1", "val1"}},
+					map[string]interface{}{"Command": "INCR", "Args": []interface{}{"counter"}},
+					map[string]interface{}{"Command": "INCR", "Args": []interface{}{"counter"}},
+					map[string]interface{}{"Command": "GET", "Args": []interface{}{"key1"}},
+				},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Ress"], ShouldResemble, []interface{}{"OK", int64(1), int64(2), "val1"})
+		})
+
+		Convey("cancel after do", func() {
+			// 请求结束后取消 ctx 不影响复用同一个连接的下一个请求
+			for i := 0; i < 200; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				_, err := d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Command": "PING"})
+				cancel()
+				So(err, ShouldBeNil)
+				_, err = d.Do(map[string]interface{}{"Command": "PING"})
+				So(err, ShouldBeNil)
+			}
+		})
+
+		Convey("close", func() {
+			_, err := d.Do(map[string]interface{}{"Command": "PING"})
+			So(err, ShouldBeNil)
+			So(Close(d), ShouldBeNil)
+			So(d.(*WrapDriver).inner.Interface().(*RedisDriver).idle, ShouldBeEmpty)
+		})
+
+		Convey("concurrent", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						_, _ = d.Do(map[string]interface{}{"Command": "INCR", "Args": []interface{}{"counter"}})
+					}
+				}()
+			}
+			wg.Wait()
+
+			res, err := d.Do(map[string]interface{}{"Command": "GET", "Args": []interface{}{"counter"}})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, "100")
+		})
+	})
+}
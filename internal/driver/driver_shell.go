@@ -2,12 +2,14 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -16,12 +18,21 @@ type ShellDriverOptions struct {
 	Shebang string   `dft:"bash"`
 	Args    []string `dft:"-c"`
 	Envs    map[string]string
+	// 默认的工作目录
+	Dir string
+	// 默认的超时时间，超时后杀掉整个进程组，为 0 时不超时
+	Timeout time.Duration
+	// stdout 和 stderr 分别保留的最大字节数，超出的部分丢弃
+	MaxOutputSize int `dft:"1048576"`
 }
 
 type ShellDriver struct {
-	shebang string
-	args    []string
-	envs    []string
+	shebang       string
+	args          []string
+	envs          []string
+	dir           string
+	timeout       time.Duration
+	maxOutputSize int
 }
 
 func NewShellDriverWithOptions(options *ShellDriverOptions) (*ShellDriver, error) {
@@ -30,10 +41,18 @@ func NewShellDriverWithOptions(options *ShellDriverOptions) (*ShellDriver, error
 		envs = append(envs, fmt.Sprintf(`%s=%s`, k, strings.TrimSpace(v)))
 	}
 
+	maxOutputSize := options.MaxOutputSize
+	if maxOutputSize <= 0 {
+		maxOutputSize = 1048576
+	}
+
 	return &ShellDriver{
-		shebang: options.Shebang,
-		args:    options.Args,
-		envs:    envs,
+		shebang:       options.Shebang,
+		args:          options.Args,
+		envs:          envs,
+		dir:           options.Dir,
+		timeout:       options.Timeout,
+		maxOutputSize: maxOutputSize,
 	}, nil
 }
 
@@ -41,6 +60,11 @@ type ShellDriverDoReq struct {
 	Command    string
 	Envs       map[string]string
 	JsonDecode bool
+	// 覆盖默认的工作目录和超时时间
+	Dir     string
+	Timeout time.Duration
+	// 写入命令标准输入的内容
+	Stdin string
 }
 
 type ShellDriverDoRes struct {
@@ -48,30 +72,97 @@ type ShellDriverDoRes struct {
 	Stderr   string
 	ExitCode int
 	Json     interface{}
+	// stdout 或 stderr 超过 MaxOutputSize 被截断
+	Truncated bool
 }
 
-func (d *ShellDriver) Do(req *ShellDriverDoReq) (*ShellDriverDoRes, error) {
+// limitedBuffer 只保留前 limit 个字节，超出的部分丢弃，不会阻塞命令的输出
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - b.buf.Len(); n < len(p) {
+		b.truncated = true
+		if n > 0 {
+			b.buf.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (d *ShellDriver) Do(ctx context.Context, req *ShellDriverDoReq) (*ShellDriverDoRes, error) {
 	var envs []string
 	for k, v := range req.Envs {
 		envs = append(envs, fmt.Sprintf(`%s=%s`, k, strings.TrimSpace(v)))
 	}
 
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = d.timeout
+	}
+	dir := req.Dir
+	if dir == "" {
+		dir = d.dir
+	}
+
+	// 不使用 exec.CommandContext，它只杀掉 shell 进程，子进程仍然持有输出管道时 Wait 不会返回
 	cmd := exec.Command(d.shebang, append(d.args, req.Command)...)
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, d.envs...)
 	cmd.Env = append(cmd.Env, envs...)
+	cmd.Dir = dir
+	if req.Stdin != "" {
+		cmd.Stdin = strings.NewReader(req.Stdin)
+	}
+	setProcessGroup(cmd)
 
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
+	stdout := &limitedBuffer{limit: d.maxOutputSize}
+	stderr := &limitedBuffer{limit: d.maxOutputSize}
 
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
 		return nil, errors.Wrap(err, "cmd.Start failed")
 	}
 
-	if err := cmd.Wait(); err != nil {
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+	timedOut := false
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+		case <-timer:
+			timedOut = true
+		case <-done:
+			return
+		}
+		killProcessGroup(cmd)
+	}()
+
+	err := cmd.Wait()
+	close(done)
+	<-exited
+	truncated := stdout.truncated || stderr.truncated
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "cmd.Wait failed")
+		}
+		if timedOut {
+			return nil, NewErrorf(errors.Wrap(err, "cmd.Wait failed"), ErrCodeTimeout, "command timeout after %v", timeout)
+		}
 		switch e := err.(type) {
 		case *exec.ExitError:
 			exitCode := -1
@@ -79,9 +170,10 @@ func (d *ShellDriver) Do(req *ShellDriverDoReq) (*ShellDriverDoRes, error) {
 				exitCode = status.ExitStatus()
 			}
 			return &ShellDriverDoRes{
-				Stdout:   stdout.String(),
-				Stderr:   stderr.String(),
-				ExitCode: exitCode,
+				Stdout:    stdout.buf.String(),
+				Stderr:    stderr.buf.String(),
+				ExitCode:  exitCode,
+				Truncated: truncated,
 			}, nil
 		}
 
@@ -90,19 +182,21 @@ func (d *ShellDriver) Do(req *ShellDriverDoReq) (*ShellDriverDoRes, error) {
 
 	if req.JsonDecode {
 		var v interface{}
-		if err := json.Unmarshal(stdout.Bytes(), &v); err != nil {
+		if err := json.Unmarshal(stdout.buf.Bytes(), &v); err != nil {
 			return nil, NewError(errors.Wrap(err, "jsoniter.Unmarshal failed"), "JsonDecodeFailed", err.Error())
 		}
 		return &ShellDriverDoRes{
-			Stderr:   stderr.String(),
-			ExitCode: 0,
-			Json:     v,
+			Stderr:    stderr.buf.String(),
+			ExitCode:  0,
+			Json:      v,
+			Truncated: truncated,
 		}, nil
 	}
 
 	return &ShellDriverDoRes{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: 0,
+		Stdout:    stdout.buf.String(),
+		Stderr:    stderr.buf.String(),
+		ExitCode:  0,
+		Truncated: truncated,
 	}, nil
 }
@@ -1,9 +1,14 @@
 package driver
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -25,10 +30,11 @@ func TestShellDriver(t *testing.T) {
 			})
 			So(err, ShouldBeNil)
 			So(res, ShouldResemble, map[string]interface{}{
-				"Stdout":   "hello world",
-				"Stderr":   "",
-				"ExitCode": int64(0),
-				"Json":     nil,
+				"Stdout":    "hello world",
+				"Stderr":    "",
+				"ExitCode":  int64(0),
+				"Json":      nil,
+				"Truncated": false,
 			})
 		})
 
@@ -42,10 +48,11 @@ func TestShellDriver(t *testing.T) {
 			})
 			So(err, ShouldBeNil)
 			So(res, ShouldResemble, map[string]interface{}{
-				"Stdout":   "hello world",
-				"Stderr":   "",
-				"ExitCode": int64(0),
-				"Json":     nil,
+				"Stdout":    "hello world",
+				"Stderr":    "",
+				"ExitCode":  int64(0),
+				"Json":      nil,
+				"Truncated": false,
 			})
 		})
 
@@ -63,6 +70,7 @@ func TestShellDriver(t *testing.T) {
 					"key1": "val1",
 					"key2": "val2",
 				},
+				"Truncated": false,
 			})
 		})
 
@@ -72,13 +80,26 @@ func TestShellDriver(t *testing.T) {
 			})
 			So(err, ShouldBeNil)
 			So(res, ShouldResemble, map[string]interface{}{
-				"Stdout":   "",
-				"Stderr":   "bash: abc: command not found\n",
-				"ExitCode": int64(127),
-				"Json":     nil,
+				"Stdout":    "",
+				"Stderr":    "bash: abc: command not found\n",
+				"ExitCode":  int64(127),
+				"Json":      nil,
+				"Truncated": false,
 			})
 		})
 
+		Convey("context canceled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			now := time.Now()
+			res, err := d.(ContextDriver).DoContext(ctx, map[string]interface{}{
+				"Command": "sleep 5",
+			})
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+			So(res, ShouldBeNil)
+			So(errors.Cause(err) == context.DeadlineExceeded, ShouldBeTrue)
+		})
+
 		Convey("json unmarshal failed", func() {
 			res, err := d.Do(map[string]interface{}{
 				"Command":    "echo -n hello world",
@@ -88,5 +109,58 @@ func TestShellDriver(t *testing.T) {
 			So(err, ShouldNotBeNil)
 			So(err.(*Error).Code, ShouldEqual, "JsonDecodeFailed")
 		})
+
+		Convey("timeout kills process group", func() {
+			now := time.Now()
+			res, err := d.Do(map[string]interface{}{
+				"Command": "sleep 5 & wait",
+				"Timeout": "100ms",
+			})
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+			So(res, ShouldBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+		})
+
+		Convey("dir and stdin", func() {
+			dir, err := filepath.EvalSymlinks(os.TempDir())
+			So(err, ShouldBeNil)
+			res, err := d.Do(map[string]interface{}{
+				"Command": "echo -n $(pwd) && cat",
+				"Dir":     dir,
+				"Stdin":   " hello world",
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Stdout"], ShouldEqual, dir+" hello world")
+		})
+	})
+
+	Convey("TestShellDriverOptions", t, func() {
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "Shell",
+			Options: &ShellDriverOptions{
+				Shebang:       "bash",
+				Args:          []string{"-c"},
+				Timeout:       100 * time.Millisecond,
+				MaxOutputSize: 4,
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("max output size", func() {
+			res, err := d.Do(map[string]interface{}{
+				"Command": "echo -n hello world; echo -n hi >&2",
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Stdout"], ShouldEqual, "hell")
+			So(res.(map[string]interface{})["Stderr"], ShouldEqual, "hi")
+			So(res.(map[string]interface{})["Truncated"], ShouldBeTrue)
+		})
+
+		Convey("default timeout", func() {
+			_, err := d.Do(map[string]interface{}{
+				"Command": "sleep 5",
+			})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+		})
 	})
 }
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package driver
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 命令在新的进程组中执行，超时时可以杀掉命令启动的所有子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
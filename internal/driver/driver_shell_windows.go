@@ -0,0 +1,12 @@
+package driver
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup windows 上只能杀掉命令本身
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,277 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SocketDriverOptions struct {
+	// tcp/udp/unix
+	Network        string `dft:"tcp"`
+	Address        string
+	ConnectTimeout time.Duration `dft:"3s"`
+	ReadTimeout    time.Duration `dft:"3s"`
+	WriteTimeout   time.Duration `dft:"3s"`
+	// 同一个 VU 复用连接，否则每次请求新建连接
+	Reuse bool
+	// 单次读取的最大字节数，udp 为数据报的最大长度
+	MaxReadSize int `dft:"65536"`
+}
+
+func NewSocketDriverWithOptions(options *SocketDriverOptions) (*SocketDriver, error) {
+	switch options.Network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix":
+	default:
+		return nil, errors.Errorf("unsupported network [%s]", options.Network)
+	}
+	if options.MaxReadSize <= 0 {
+		options.MaxReadSize = 65536
+	}
+
+	return &SocketDriver{
+		options: options,
+		dialer:  &net.Dialer{Timeout: options.ConnectTimeout},
+	}, nil
+}
+
+type SocketDriver struct {
+	options *SocketDriverOptions
+	dialer  *net.Dialer
+}
+
+type SocketDoReq struct {
+	// 发送的数据，为空时不发送
+	Payload string
+	// Payload，Delimiter 以及响应的编码 text/hex/base64，默认为 text
+	Encoding         string
+	ResponseEncoding string
+
+	// 读取方式，按 Delimiter，LengthPrefix，ReadBytes 的顺序生效，都为空时不读取
+	// udp 读取一个数据报
+	// Delimiter 读到分隔符为止，响应包含分隔符
+	Delimiter string
+	// LengthPrefix 先读取指定字节数的长度，默认大端，再读取该长度的数据，响应不包含长度
+	LengthPrefix             int
+	LengthPrefixLittleEndian bool
+	// ReadBytes 读取固定字节数
+	ReadBytes int
+}
+
+type SocketDoRes struct {
+	Response    string
+	Length      int
+	Reused      bool
+	ConnectTime time.Duration
+}
+
+type socketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (d *SocketDriver) Do(ctx context.Context, req *SocketDoReq) (*SocketDoRes, error) {
+	payload, err := decodePayload(req.Payload, req.Encoding)
+	if err != nil {
+		return nil, NewErrorf(err, "socket.InvalidRequest", "decode Payload failed, err: [%s]", err.Error())
+	}
+	delimiter, err := decodePayload(req.Delimiter, req.Encoding)
+	if err != nil {
+		return nil, NewErrorf(err, "socket.InvalidRequest", "decode Delimiter failed, err: [%s]", err.Error())
+	}
+	switch req.LengthPrefix {
+	case 0, 1, 2, 4, 8:
+	default:
+		return nil, NewErrorf(nil, "socket.InvalidRequest", "LengthPrefix should be one of 1, 2, 4, 8")
+	}
+	switch req.ResponseEncoding {
+	case "", "text", "hex", "base64":
+	default:
+		return nil, NewErrorf(nil, "socket.InvalidRequest", "unknown ResponseEncoding [%s]", req.ResponseEncoding)
+	}
+
+	res := &SocketDoRes{}
+	conn, session, err := d.conn(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+	release := func(broken bool) {
+		if session != nil && !broken {
+			return
+		}
+		if session != nil {
+			session.Delete(d)
+		}
+		_ = conn.conn.Close()
+	}
+
+	// ctx 取消时让阻塞的读写立即返回，返回前等待协程退出，避免复用的连接在下一个请求中被设置超时
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	defer func() {
+		close(done)
+		<-exited
+	}()
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = conn.conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	if len(payload) != 0 {
+		if err := conn.conn.SetWriteDeadline(deadline(ctx, d.options.WriteTimeout)); err != nil {
+			release(true)
+			return nil, errors.Wrap(err, "conn.SetWriteDeadline failed")
+		}
+		if _, err := conn.conn.Write(payload); err != nil {
+			release(true)
+			return nil, socketError(err, "socket.WriteFailed")
+		}
+	}
+
+	if err := conn.conn.SetReadDeadline(deadline(ctx, d.options.ReadTimeout)); err != nil {
+		release(true)
+		return nil, errors.Wrap(err, "conn.SetReadDeadline failed")
+	}
+	buf, err := d.read(conn, req, delimiter)
+	if err != nil {
+		release(true)
+		return nil, err
+	}
+	release(false)
+
+	res.Length = len(buf)
+	res.Response = encodeResponse(buf, req.ResponseEncoding)
+	return res, nil
+}
+
+func (d *SocketDriver) conn(ctx context.Context, res *SocketDoRes) (*socketConn, *Session, error) {
+	var session *Session
+	if d.options.Reuse {
+		if session = VUSession(ctx); session != nil {
+			if v, ok := session.Get(d); ok {
+				res.Reused = true
+				return v.(*socketConn), session, nil
+			}
+		}
+	}
+
+	start := time.Now()
+	c, err := d.dialer.DialContext(ctx, d.options.Network, d.options.Address)
+	if err != nil {
+		return nil, nil, socketError(err, "socket.ConnectFailed")
+	}
+	res.ConnectTime = time.Since(start)
+
+	conn := &socketConn{conn: c, reader: bufio.NewReaderSize(c, d.options.MaxReadSize)}
+	if session != nil {
+		session.Set(d, conn, func() {
+			_ = c.Close()
+		})
+	}
+	return conn, session, nil
+}
+
+func (d *SocketDriver) read(conn *socketConn, req *SocketDoReq, delimiter []byte) ([]byte, error) {
+	if len(delimiter) == 0 && req.LengthPrefix == 0 && req.ReadBytes == 0 {
+		return nil, nil
+	}
+
+	// udp 每次读取一个完整的数据报
+	if strings.HasPrefix(d.options.Network, "udp") {
+		buf := make([]byte, d.options.MaxReadSize)
+		n, err := conn.conn.Read(buf)
+		if err != nil {
+			return nil, socketError(err, "socket.ReadFailed")
+		}
+		return buf[:n], nil
+	}
+
+	if len(delimiter) != 0 {
+		var buf []byte
+		for !bytes.HasSuffix(buf, delimiter) {
+			b, err := conn.reader.ReadByte()
+			if err != nil {
+				return nil, socketError(err, "socket.ReadFailed")
+			}
+			buf = append(buf, b)
+			if len(buf) > d.options.MaxReadSize {
+				return nil, NewErrorf(nil, "socket.ResponseTooLarge", "delimiter not found in %d bytes", d.options.MaxReadSize)
+			}
+		}
+		return buf, nil
+	}
+
+	n := req.ReadBytes
+	if req.LengthPrefix != 0 {
+		header := make([]byte, req.LengthPrefix)
+		if _, err := io.ReadFull(conn.reader, header); err != nil {
+			return nil, socketError(err, "socket.ReadFailed")
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if req.LengthPrefixLittleEndian {
+			order = binary.LittleEndian
+		}
+		switch req.LengthPrefix {
+		case 1:
+			n = int(header[0])
+		case 2:
+			n = int(order.Uint16(header))
+		case 4:
+			n = int(order.Uint32(header))
+		case 8:
+			n = int(order.Uint64(header))
+		}
+	}
+	if n > d.options.MaxReadSize || n < 0 {
+		return nil, NewErrorf(nil, "socket.ResponseTooLarge", "response length [%d] exceeds MaxReadSize [%d]", n, d.options.MaxReadSize)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn.reader, buf); err != nil {
+		return nil, socketError(err, "socket.ReadFailed")
+	}
+	return buf, nil
+}
+
+// socketError 超时的错误码为 ErrCodeTimeout，其他为 code
+func socketError(err error, code string) error {
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		code = ErrCodeTimeout
+	}
+	return NewError(err, code, err.Error())
+}
+
+func decodePayload(str string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "text":
+		return []byte(str), nil
+	case "hex":
+		return hex.DecodeString(str)
+	case "base64":
+		return base64.StdEncoding.DecodeString(str)
+	}
+	return nil, errors.Errorf("unknown encoding [%s]", encoding)
+}
+
+func encodeResponse(buf []byte, encoding string) string {
+	switch encoding {
+	case "hex":
+		return hex.EncodeToString(buf)
+	case "base64":
+		return base64.StdEncoding.EncodeToString(buf)
+	}
+	return string(buf)
+}
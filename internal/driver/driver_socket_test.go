@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveEcho(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			_, _ = io.Copy(conn, conn)
+		}()
+	}
+}
+
+func TestSocketDriver(t *testing.T) {
+	Convey("TestSocketDriver", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+		go serveEcho(listener)
+
+		newDriver := func(options *SocketDriverOptions) Driver {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{Type: "Socket", Options: options})
+			So(err, ShouldBeNil)
+			return d
+		}
+
+		Convey("tcp", func() {
+			d := newDriver(&SocketDriverOptions{
+				Network:     "tcp",
+				Address:     listener.Addr().String(),
+				ReadTimeout: 100 * time.Millisecond,
+				MaxReadSize: 1024,
+			})
+
+			res, err := d.Do(map[string]interface{}{
+				"Payload":   "hello world\r\n",
+				"Delimiter": "\r\n",
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "hello world\r\n")
+			So(res.(map[string]interface{})["Reused"], ShouldBeFalse)
+
+			res, err = d.Do(map[string]interface{}{
+				"Payload":          "000568656c6c6f",
+				"Encoding":         "hex",
+				"LengthPrefix":     2,
+				"ResponseEncoding": "text",
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "hello")
+			So(res.(map[string]interface{})["Length"], ShouldEqual, 5)
+
+			res, err = d.Do(map[string]interface{}{
+				"Payload":          "aGVsbG8gd29ybGQ=",
+				"Encoding":         "base64",
+				"ReadBytes":        5,
+				"ResponseEncoding": "hex",
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "68656c6c6f")
+
+			_, err = d.Do(map[string]interface{}{
+				"Payload":   "hello",
+				"ReadBytes": 10,
+			})
+			So(err, ShouldNotBeNil)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+
+			_, err = d.Do(map[string]interface{}{
+				"Payload":  "xyz",
+				"Encoding": "hex",
+			})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "socket.InvalidRequest")
+		})
+
+		Convey("reuse", func() {
+			d := newDriver(&SocketDriverOptions{
+				Network: "tcp",
+				Address: listener.Addr().String(),
+				Reuse:   true,
+			}).(ContextDriver)
+
+			session := NewSession()
+			defer session.Close()
+			ctx := WithVUSession(context.Background(), session)
+
+			res, err := d.DoContext(ctx, map[string]interface{}{"Payload": "a\nb\n", "Delimiter": "\n"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "a\n")
+			So(res.(map[string]interface{})["Reused"], ShouldBeFalse)
+
+			// 上次多读取的数据在同一个连接中保留
+			res, err = d.DoContext(ctx, map[string]interface{}{"Delimiter": "\n"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "b\n")
+			So(res.(map[string]interface{})["Reused"], ShouldBeTrue)
+
+			// 请求结束后取消 step 的 ctx 不影响下一个请求
+			for i := 0; i < 100; i++ {
+				stepCtx, cancel := context.WithCancel(ctx)
+				res, err = d.DoContext(stepCtx, map[string]interface{}{"Payload": "c\n", "Delimiter": "\n"})
+				cancel()
+				So(err, ShouldBeNil)
+				So(res.(map[string]interface{})["Response"], ShouldEqual, "c\n")
+			}
+		})
+
+		Convey("unix", func() {
+			dir, err := ioutil.TempDir("", "benv-socket")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			unixListener, err := net.Listen("unix", filepath.Join(dir, "echo.sock"))
+			So(err, ShouldBeNil)
+			defer unixListener.Close()
+			go serveEcho(unixListener)
+
+			d := newDriver(&SocketDriverOptions{
+				Network: "unix",
+				Address: filepath.Join(dir, "echo.sock"),
+			})
+			res, err := d.Do(map[string]interface{}{"Payload": "hello", "ReadBytes": 5})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "hello")
+		})
+
+		Convey("udp", func() {
+			packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			defer packetConn.Close()
+			go func() {
+				buf := make([]byte, 1024)
+				for {
+					n, addr, err := packetConn.ReadFrom(buf)
+					if err != nil {
+						return
+					}
+					_, _ = packetConn.WriteTo(buf[:n], addr)
+				}
+			}()
+
+			d := newDriver(&SocketDriverOptions{
+				Network: "udp",
+				Address: packetConn.LocalAddr().String(),
+			})
+			res, err := d.Do(map[string]interface{}{"Payload": "hello world", "ReadBytes": 1})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Response"], ShouldEqual, "hello world")
+		})
+	})
+}
@@ -0,0 +1,271 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type SqlDriverOptions struct {
+	// 使用 database/sql 注册的驱动名，如 mysql，postgres
+	DriverName      string `dft:"mysql"`
+	DSN             string
+	MaxOpenConns    int           `dft:"10"`
+	MaxIdleConns    int           `dft:"10"`
+	ConnMaxLifetime time.Duration `dft:"60s"`
+	PingTimeout     time.Duration `dft:"3s"`
+}
+
+func NewSqlDriverWithOptions(options *SqlDriverOptions) (*SqlDriver, error) {
+	db, err := sql.Open(options.DriverName, options.DSN)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sql.Open [%s] failed", options.DriverName)
+	}
+	db.SetMaxOpenConns(options.MaxOpenConns)
+	db.SetMaxIdleConns(options.MaxIdleConns)
+	db.SetConnMaxLifetime(options.ConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.PingTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "db.PingContext failed")
+	}
+
+	bindVar := "?"
+	if options.DriverName == "postgres" || options.DriverName == "pgx" {
+		bindVar = "$"
+	}
+
+	return &SqlDriver{
+		db:      db,
+		options: options,
+		bindVar: bindVar,
+	}, nil
+}
+
+type SqlDriver struct {
+	db      *sql.DB
+	options *SqlDriverOptions
+	bindVar string
+}
+
+type SqlDoReq struct {
+	// Query 返回查询结果，Exec 返回影响的行数，Begin/Commit/Rollback 控制一个 unit 内的事务，默认为 Query
+	Method string
+	SQL    string
+	// 位置参数
+	Args []interface{}
+	// 命名参数，SQL 中用 :name 引用
+	NamedArgs map[string]interface{}
+}
+
+type SqlDoRes struct {
+	Rows         []map[string]interface{}
+	RowsAffected int64
+	LastInsertId int64
+}
+
+type sqlExecutor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (d *SqlDriver) Do(ctx context.Context, req *SqlDoReq) (*SqlDoRes, error) {
+	switch req.Method {
+	case "Begin":
+		return d.begin(ctx)
+	case "Commit", "Rollback":
+		return d.finish(ctx, req.Method)
+	case "", "Query", "Exec":
+	default:
+		return nil, NewErrorf(nil, "sql.InvalidMethod", "unknown method [%s]", req.Method)
+	}
+
+	query, args, err := d.bind(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在事务中时使用事务执行
+	var executor sqlExecutor = d.db
+	if session := UnitSession(ctx); session != nil {
+		if tx, ok := session.Get(d); ok {
+			executor = tx.(*sql.Tx)
+		}
+	}
+
+	if req.Method == "Exec" {
+		result, err := executor.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, errors.Wrap(err, "ExecContext failed")
+		}
+		res := &SqlDoRes{}
+		// 部分驱动不支持，如 postgres 不支持 LastInsertId
+		res.RowsAffected, _ = result.RowsAffected()
+		res.LastInsertId, _ = result.LastInsertId()
+		return res, nil
+	}
+
+	rows, err := executor.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryContext failed")
+	}
+	defer rows.Close()
+
+	res, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &SqlDoRes{Rows: res}, nil
+}
+
+// Close 关闭连接池
+func (d *SqlDriver) Close() error {
+	return d.db.Close()
+}
+
+func (d *SqlDriver) begin(ctx context.Context) (*SqlDoRes, error) {
+	session := UnitSession(ctx)
+	if session == nil {
+		return nil, NewErrorf(nil, "sql.NoSession", "transaction can only be used in a unit")
+	}
+	if _, ok := session.Get(d); ok {
+		return nil, NewErrorf(nil, "sql.InTransaction", "transaction already began")
+	}
+
+	// 事务跨越多个 step，不能使用单个 step 的 ctx，unit 结束时未提交的事务会被回滚
+	tx, err := d.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.BeginTx failed")
+	}
+	session.Set(d, tx, func() {
+		_ = tx.Rollback()
+	})
+
+	return &SqlDoRes{}, nil
+}
+
+func (d *SqlDriver) finish(ctx context.Context, method string) (*SqlDoRes, error) {
+	session := UnitSession(ctx)
+	if session == nil {
+		return nil, NewErrorf(nil, "sql.NoSession", "transaction can only be used in a unit")
+	}
+	v, ok := session.Get(d)
+	if !ok {
+		return nil, NewErrorf(nil, "sql.NotInTransaction", "%s without Begin", method)
+	}
+	session.Delete(d)
+
+	tx := v.(*sql.Tx)
+	if method == "Rollback" {
+		if err := tx.Rollback(); err != nil {
+			return nil, errors.Wrap(err, "tx.Rollback failed")
+		}
+		return &SqlDoRes{}, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "tx.Commit failed")
+	}
+	return &SqlDoRes{}, nil
+}
+
+// bind 将 SQL 中的 :name 替换成驱动的占位符，mysql 为 ?，postgres 为 $1
+func (d *SqlDriver) bind(req *SqlDoReq) (string, []interface{}, error) {
+	if len(req.NamedArgs) == 0 {
+		return req.SQL, req.Args, nil
+	}
+	if len(req.Args) != 0 {
+		return "", nil, NewErrorf(nil, "sql.InvalidRequest", "Args and NamedArgs can not be used together")
+	}
+
+	var buf strings.Builder
+	var args []interface{}
+	query := req.SQL
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		// 跳过字符串
+		if c == '\'' || c == '"' || c == '`' {
+			j := strings.IndexByte(query[i+1:], c)
+			if j == -1 {
+				buf.WriteString(query[i:])
+				break
+			}
+			buf.WriteString(query[i : i+j+2])
+			i += j + 1
+			continue
+		}
+		// 跳过 postgres 的类型转换 ::
+		if c == ':' && i+1 < len(query) && query[i+1] == ':' {
+			buf.WriteString("::")
+			i++
+			continue
+		}
+		if c != ':' || i+1 >= len(query) || !isNameChar(query[i+1]) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && isNameChar(query[j]) {
+			j++
+		}
+		name := query[i+1 : j]
+		v, ok := req.NamedArgs[name]
+		if !ok {
+			return "", nil, NewErrorf(nil, "sql.InvalidRequest", "named arg [%s] not found", name)
+		}
+		args = append(args, v)
+		buf.WriteString(d.bindVar)
+		if d.bindVar == "$" {
+			buf.WriteString(strconv.Itoa(len(args)))
+		}
+		i = j - 1
+	}
+
+	return buf.String(), args, nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "rows.Columns failed")
+	}
+
+	res := []map[string]interface{}{}
+	for rows.Next() {
+		vals := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan failed")
+		}
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			// mysql 等驱动以 []byte 返回字符串
+			if buf, ok := vals[i].([]byte); ok {
+				row[column] = string(buf)
+				continue
+			}
+			row[column] = vals[i]
+		}
+		res = append(res, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err failed")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,165 @@
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hatlonely/go-kit/refx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSqlDriver(t *testing.T) {
+	Convey("TestSqlDriver", t, func() {
+		dir, err := ioutil.TempDir("", "benv-sql")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "Sql",
+			Options: &SqlDriverOptions{
+				DriverName:   "sqlite3",
+				DSN:          filepath.Join(dir, "test.db"),
+				MaxOpenConns: 2,
+			},
+		})
+		So(err, ShouldBeNil)
+		cd := d.(ContextDriver)
+
+		_, err = d.Do(map[string]interface{}{
+			"Method": "Exec",
+			"SQL":    "CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)",
+		})
+		So(err, ShouldBeNil)
+
+		res, err := d.Do(map[string]interface{}{
+			"Method": "Exec",
+			"SQL":    "INSERT INTO user (name, age) VALUES (?, ?)",
+			"Args":   []interface{}{"hatlonely", 18},
+		})
+		So(err, ShouldBeNil)
+		So(res.(map[string]interface{})["RowsAffected"], ShouldEqual, int64(1))
+		So(res.(map[string]interface{})["LastInsertId"], ShouldEqual, int64(1))
+
+		Convey("query", func() {
+			_, err := d.Do(map[string]interface{}{
+				"Method": "Exec",
+				"SQL":    "INSERT INTO user (name, age) VALUES (:name, :age)",
+				"NamedArgs": map[string]interface{}{
+					"name": "playjokes",
+					"age":  20,
+				},
+			})
+			So(err, ShouldBeNil)
+
+			res, err := d.Do(map[string]interface{}{
+				"SQL":  "SELECT id, name, age FROM user WHERE age >= ? ORDER BY id",
+				"Args": []interface{}{18},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Rows"], ShouldResemble, []interface{}{
+				map[string]interface{}{"id": int64(1), "name": "hatlonely", "age": int64(18)},
+				map[string]interface{}{"id": int64(2), "name": "playjokes", "age": int64(20)},
+			})
+
+			res, err = d.Do(map[string]interface{}{
+				"SQL": "SELECT name FROM user WHERE name = :name AND name != ':name'",
+				"NamedArgs": map[string]interface{}{
+					"name": "playjokes",
+				},
+			})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Rows"], ShouldResemble, []interface{}{
+				map[string]interface{}{"name": "playjokes"},
+			})
+
+			_, err = d.Do(map[string]interface{}{
+				"SQL": "SELECT * FROM unknown",
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("transaction", func() {
+			count := func() interface{} {
+				res, err := d.Do(map[string]interface{}{"SQL": "SELECT COUNT(*) AS n FROM user"})
+				So(err, ShouldBeNil)
+				return res.(map[string]interface{})["Rows"].([]interface{})[0].(map[string]interface{})["n"]
+			}
+			insert := map[string]interface{}{
+				"Method": "Exec",
+				"SQL":    "INSERT INTO user (name, age) VALUES (?, ?)",
+				"Args":   []interface{}{"playjokes", 20},
+			}
+
+			_, err := d.Do(map[string]interface{}{"Method": "Begin"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "sql.NoSession")
+
+			session := NewSession()
+			ctx := WithUnitSession(context.Background(), session)
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Begin"})
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx, insert)
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Rollback"})
+			So(err, ShouldBeNil)
+			So(count(), ShouldEqual, int64(1))
+
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Commit"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "sql.NotInTransaction")
+
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Begin"})
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx, insert)
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Commit"})
+			So(err, ShouldBeNil)
+			So(count(), ShouldEqual, int64(2))
+
+			// unit 结束时未提交的事务被回滚
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Begin"})
+			So(err, ShouldBeNil)
+			_, err = cd.DoContext(ctx, insert)
+			So(err, ShouldBeNil)
+			session.Close()
+			So(count(), ShouldEqual, int64(2))
+		})
+
+		Convey("close", func() {
+			So(Close(d), ShouldBeNil)
+			_, err := d.Do(map[string]interface{}{"SQL": "SELECT 1"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSqlDriver_bind(t *testing.T) {
+	Convey("TestSqlDriver_bind", t, func() {
+		req := &SqlDoReq{
+			SQL: "SELECT * FROM user WHERE name = :name AND age > :age AND created::date = '2021-01-01 00:00:00' AND tag = ':name'",
+			NamedArgs: map[string]interface{}{
+				"name": "hatlonely",
+				"age":  18,
+			},
+		}
+
+		query, args, err := (&SqlDriver{bindVar: "?"}).bind(req)
+		So(err, ShouldBeNil)
+		So(query, ShouldEqual, "SELECT * FROM user WHERE name = ? AND age > ? AND created::date = '2021-01-01 00:00:00' AND tag = ':name'")
+		So(args, ShouldResemble, []interface{}{"hatlonely", 18})
+
+		query, args, err = (&SqlDriver{bindVar: "$"}).bind(req)
+		So(err, ShouldBeNil)
+		So(query, ShouldEqual, "SELECT * FROM user WHERE name = $1 AND age > $2 AND created::date = '2021-01-01 00:00:00' AND tag = ':name'")
+		So(args, ShouldResemble, []interface{}{"hatlonely", 18})
+
+		_, _, err = (&SqlDriver{bindVar: "?"}).bind(&SqlDoReq{
+			SQL:       "SELECT * FROM user WHERE name = :unknown",
+			NamedArgs: map[string]interface{}{"name": "hatlonely"},
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,305 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/benv2/internal/eval"
+)
+
+type WebSocketDriverOptions struct {
+	URL              string
+	Header           map[string]string
+	HandshakeTimeout time.Duration `dft:"3s"`
+	// Receive 默认的等待时间
+	Timeout time.Duration `dft:"6s"`
+	// 未被 Receive 读取的消息缓存数量，缓存已满时丢弃最早的消息
+	BufferSize int `dft:"1024"`
+}
+
+func NewWebSocketDriverWithOptions(options *WebSocketDriverOptions) (*WebSocketDriver, error) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = 1024
+	}
+	header := http.Header{}
+	for key, val := range options.Header {
+		header.Set(key, val)
+	}
+
+	return &WebSocketDriver{
+		options: options,
+		header:  header,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: options.HandshakeTimeout,
+		},
+	}, nil
+}
+
+// WebSocketDriver 的连接保存在 VU 的 Session 中，同一个 VU 的多个 unit 共享一个连接
+type WebSocketDriver struct {
+	options *WebSocketDriverOptions
+	header  http.Header
+	dialer  *websocket.Dialer
+
+	// 缓存 Until 表达式
+	predicates sync.Map
+}
+
+type WebSocketDoReq struct {
+	// Connect/Send/Receive/Request/Close，Request 为 Send 之后 Receive
+	Method string
+	// Connect 时覆盖 URL 和追加的 Header
+	URL    string
+	Header map[string]string
+	// 发送消息的类型 text/binary/json，binary 的 Message 为 base64 编码
+	Type    string
+	Message interface{}
+	// 等待满足条件的消息，变量 msg 为收到的消息，如 msg.Json.id == 123，为空时返回第一条消息
+	Until   string
+	Timeout time.Duration
+}
+
+type WebSocketDoRes struct {
+	// 收到的消息，Type 为 text/binary，binary 的 Message 为 base64 编码，Json 为 Message 按 json 解析的结果
+	Type    string
+	Message string
+	Json    interface{}
+	// 等待期间丢弃的不满足条件的消息数
+	Skipped int
+	// 连接建立以来因为缓存已满丢弃的消息数
+	Dropped int
+
+	// Connect 时已经建立的连接被复用
+	Reused        bool
+	HandshakeTime time.Duration
+	// 从最近一次 Send 到收到满足条件的消息的时间
+	RoundTripTime time.Duration
+}
+
+type wsConn struct {
+	conn     *websocket.Conn
+	messages chan map[string]interface{}
+	// 读取协程退出的原因，messages 关闭后可读
+	err      error
+	lastSend time.Time
+	dropped  int64
+}
+
+func (c *wsConn) readLoop() {
+	defer close(c.messages)
+	for {
+		messageType, buf, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		msg := map[string]interface{}{"Type": "text", "Message": string(buf), "Json": nil}
+		if messageType == websocket.BinaryMessage {
+			msg["Type"] = "binary"
+			msg["Message"] = base64.StdEncoding.EncodeToString(buf)
+		}
+		var v interface{}
+		if err := jsoniter.Unmarshal(buf, &v); err == nil {
+			msg["Json"] = v
+		}
+		c.push(msg)
+	}
+}
+
+// push 缓存消息，缓存已满时丢弃最早的消息，读取协程不会阻塞，连接关闭后可以退出
+func (c *wsConn) push(msg map[string]interface{}) {
+	for {
+		select {
+		case c.messages <- msg:
+			return
+		default:
+		}
+		select {
+		case <-c.messages:
+			atomic.AddInt64(&c.dropped, 1)
+		default:
+		}
+	}
+}
+
+func (d *WebSocketDriver) Do(ctx context.Context, req *WebSocketDoReq) (*WebSocketDoRes, error) {
+	session := VUSession(ctx)
+	if session == nil {
+		return nil, NewErrorf(nil, "websocket.NoSession", "websocket driver can only be used in a VU")
+	}
+
+	if req.Method == "Connect" {
+		return d.connect(ctx, session, req)
+	}
+
+	v, ok := session.Get(d)
+	if !ok {
+		return nil, NewErrorf(nil, "websocket.NotConnected", "connect before %s", req.Method)
+	}
+	conn := v.(*wsConn)
+
+	switch req.Method {
+	case "Close":
+		session.Delete(d)
+		_ = conn.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		_ = conn.conn.Close()
+		return &WebSocketDoRes{}, nil
+	case "Send":
+		if err := d.send(ctx, session, conn, req); err != nil {
+			return nil, err
+		}
+		return &WebSocketDoRes{}, nil
+	case "Receive":
+		return d.receive(ctx, session, conn, req)
+	case "Request":
+		if err := d.send(ctx, session, conn, req); err != nil {
+			return nil, err
+		}
+		return d.receive(ctx, session, conn, req)
+	}
+
+	return nil, NewErrorf(nil, "websocket.InvalidMethod", "unknown method [%s]", req.Method)
+}
+
+func (d *WebSocketDriver) connect(ctx context.Context, session *Session, req *WebSocketDoReq) (*WebSocketDoRes, error) {
+	if _, ok := session.Get(d); ok {
+		return &WebSocketDoRes{Reused: true}, nil
+	}
+
+	url := req.URL
+	if url == "" {
+		url = d.options.URL
+	}
+	header := d.header.Clone()
+	for key, val := range req.Header {
+		header.Set(key, val)
+	}
+
+	start := time.Now()
+	c, _, err := d.dialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, NewErrorf(err, "websocket.ConnectFailed", "dialer.DialContext [%s] failed, err: [%s]", url, err.Error())
+	}
+	handshakeTime := time.Since(start)
+
+	conn := &wsConn{conn: c, messages: make(chan map[string]interface{}, d.options.BufferSize)}
+	go conn.readLoop()
+	session.Set(d, conn, func() {
+		_ = c.Close()
+	})
+
+	return &WebSocketDoRes{HandshakeTime: handshakeTime}, nil
+}
+
+func (d *WebSocketDriver) send(ctx context.Context, session *Session, conn *wsConn, req *WebSocketDoReq) error {
+	var messageType int
+	var buf []byte
+	switch req.Type {
+	case "", "text":
+		messageType = websocket.TextMessage
+		str, ok := req.Message.(string)
+		if !ok {
+			return NewErrorf(nil, "websocket.InvalidRequest", "text message should be a string")
+		}
+		buf = []byte(str)
+	case "binary":
+		messageType = websocket.BinaryMessage
+		str, ok := req.Message.(string)
+		if !ok {
+			return NewErrorf(nil, "websocket.InvalidRequest", "binary message should be a base64 string")
+		}
+		var err error
+		if buf, err = base64.StdEncoding.DecodeString(str); err != nil {
+			return NewErrorf(err, "websocket.InvalidRequest", "base64 decode failed, err: [%s]", err.Error())
+		}
+	case "json":
+		messageType = websocket.TextMessage
+		var err error
+		if buf, err = jsoniter.Marshal(req.Message); err != nil {
+			return errors.Wrap(err, "jsoniter.Marshal failed")
+		}
+	default:
+		return NewErrorf(nil, "websocket.InvalidRequest", "unknown message type [%s]", req.Type)
+	}
+
+	conn.lastSend = time.Now()
+	if err := conn.conn.SetWriteDeadline(deadline(ctx, d.options.Timeout)); err != nil {
+		return errors.Wrap(err, "conn.SetWriteDeadline failed")
+	}
+	if err := conn.conn.WriteMessage(messageType, buf); err != nil {
+		session.Delete(d)
+		_ = conn.conn.Close()
+		return NewErrorf(err, "websocket.Closed", "conn.WriteMessage failed, err: [%s]", err.Error())
+	}
+	return nil
+}
+
+func (d *WebSocketDriver) receive(ctx context.Context, session *Session, conn *wsConn, req *WebSocketDoReq) (*WebSocketDoRes, error) {
+	var predicate gval.Evaluable
+	if req.Until != "" {
+		v, ok := d.predicates.Load(req.Until)
+		if !ok {
+			e, err := eval.Lang.NewEvaluable(req.Until)
+			if err != nil {
+				return nil, NewErrorf(err, "websocket.InvalidRequest", "invalid Until [%s], err: [%s]", req.Until, err.Error())
+			}
+			d.predicates.Store(req.Until, e)
+			v = e
+		}
+		predicate = v.(gval.Evaluable)
+	}
+
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = d.options.Timeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	skipped := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "wait for message failed")
+		case <-timer.C:
+			return nil, NewErrorf(nil, ErrCodeTimeout, "no matched message in %v, skipped [%d]", timeout, skipped)
+		case msg, ok := <-conn.messages:
+			if !ok {
+				session.Delete(d)
+				_ = conn.conn.Close()
+				return nil, NewErrorf(conn.err, "websocket.Closed", "connection closed, err: [%v]", conn.err)
+			}
+			if predicate != nil {
+				matched, err := predicate.EvalBool(ctx, map[string]interface{}{"msg": msg})
+				if err != nil {
+					return nil, NewErrorf(err, "websocket.InvalidRequest", "evaluate Until [%s] failed, err: [%s]", req.Until, err.Error())
+				}
+				if !matched {
+					skipped++
+					continue
+				}
+			}
+			res := &WebSocketDoRes{
+				Type:    msg["Type"].(string),
+				Message: msg["Message"].(string),
+				Json:    msg["Json"],
+				Skipped: skipped,
+				Dropped: int(atomic.LoadInt64(&conn.dropped)),
+			}
+			if !conn.lastSend.IsZero() {
+				res.RoundTripTime = time.Since(conn.lastSend)
+			}
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebSocketDriver(t *testing.T) {
+	Convey("TestWebSocketDriver", t, func() {
+		upgrader := websocket.Upgrader{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				messageType, buf, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err := conn.WriteMessage(messageType, buf); err != nil {
+					return
+				}
+			}
+		}))
+		defer server.Close()
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "WebSocket",
+			Options: &WebSocketDriverOptions{
+				URL:              "ws" + strings.TrimPrefix(server.URL, "http"),
+				HandshakeTimeout: time.Second,
+				Timeout:          time.Second,
+				BufferSize:       16,
+			},
+		})
+		So(err, ShouldBeNil)
+		cd := d.(ContextDriver)
+
+		_, err = d.Do(map[string]interface{}{"Method": "Connect"})
+		So(errors.Cause(err).(*Error).Code, ShouldEqual, "websocket.NoSession")
+
+		session := NewSession()
+		defer session.Close()
+		ctx := WithVUSession(context.Background(), session)
+
+		_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Send", "Message": "hello"})
+		So(errors.Cause(err).(*Error).Code, ShouldEqual, "websocket.NotConnected")
+
+		res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Connect"})
+		So(err, ShouldBeNil)
+		So(res.(map[string]interface{})["Reused"], ShouldBeFalse)
+		So(res.(map[string]interface{})["HandshakeTime"], ShouldBeGreaterThan, 0)
+
+		res, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Connect"})
+		So(err, ShouldBeNil)
+		So(res.(map[string]interface{})["Reused"], ShouldBeTrue)
+
+		Convey("request", func() {
+			res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Request", "Message": "hello world"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Type"], ShouldEqual, "text")
+			So(res.(map[string]interface{})["Message"], ShouldEqual, "hello world")
+			So(res.(map[string]interface{})["RoundTripTime"], ShouldBeGreaterThan, 0)
+
+			res, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Request", "Type": "binary", "Message": "aGVsbG8="})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Type"], ShouldEqual, "binary")
+			So(res.(map[string]interface{})["Message"], ShouldEqual, "aGVsbG8=")
+		})
+
+		Convey("receive until", func() {
+			for i := 1; i <= 3; i++ {
+				_, err := cd.DoContext(ctx, map[string]interface{}{
+					"Method":  "Send",
+					"Type":    "json",
+					"Message": map[string]interface{}{"id": i},
+				})
+				So(err, ShouldBeNil)
+			}
+
+			res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Receive", "Until": "msg.Json.id == 2"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Json"], ShouldResemble, map[string]interface{}{"id": int64(2)})
+			So(res.(map[string]interface{})["Skipped"], ShouldEqual, 1)
+
+			res, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Receive"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Message"], ShouldEqual, `{"id":3}`)
+
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Receive", "Timeout": "100ms"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, ErrCodeTimeout)
+		})
+
+		Convey("buffer full", func() {
+			// 缓存 16 条消息，未读取的消息超过缓存时丢弃最早的消息
+			for i := 0; i < 20; i++ {
+				_, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Send", "Message": strconv.Itoa(i)})
+				So(err, ShouldBeNil)
+			}
+			time.Sleep(300 * time.Millisecond)
+			res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Receive"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Message"], ShouldEqual, "4")
+			So(res.(map[string]interface{})["Dropped"], ShouldEqual, 4)
+
+			res, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Request", "Message": "hello", "Until": `msg.Message == "hello"`})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Skipped"], ShouldEqual, 15)
+		})
+
+		Convey("close", func() {
+			_, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Close"})
+			So(err, ShouldBeNil)
+
+			_, err = cd.DoContext(ctx, map[string]interface{}{"Method": "Receive"})
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "websocket.NotConnected")
+
+			res, err := cd.DoContext(ctx, map[string]interface{}{"Method": "Connect"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Reused"], ShouldBeFalse)
+		})
+	})
+}
@@ -1,12 +1,17 @@
 package driver
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 
 	"github.com/pkg/errors"
 )
 
+// ErrCodeTimeout 超时的错误码，驱动自身的超时以及 step 的超时都使用这个错误码
+const ErrCodeTimeout = "Timeout"
+
 func NewError(err error, code string, message string) *Error {
 	if err != nil {
 		err = errors.Errorf("[%s]: %s", code, message)
@@ -23,6 +28,14 @@ func NewErrorf(err error, code string, format string, v ...interface{}) *Error {
 	return NewError(err, code, fmt.Sprintf(format, v...))
 }
 
+// timeoutError 将网络超时转换成 ErrCodeTimeout 的错误，ctx 结束导致的超时由框架处理
+func timeoutError(ctx context.Context, err error) error {
+	if e, ok := errors.Cause(err).(net.Error); ok && e.Timeout() && ctx.Err() == nil {
+		return NewError(err, ErrCodeTimeout, err.Error())
+	}
+	return err
+}
+
 type Error struct {
 	err     error
 	Code    string
@@ -0,0 +1,408 @@
+package driver
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	openapiutil "github.com/alibabacloud-go/openapi-util/service"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/hatlonely/go-kit/refx"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+func RegisterHttpSigner(key string, constructor interface{}) {
+	refx.Register("driver.HttpSigner", key, constructor)
+}
+
+func NewHttpSignerWithOptions(options *refx.TypeOptions, opts ...refx.Option) (HttpSigner, error) {
+	if options.Namespace == "" {
+		options.Namespace = "driver.HttpSigner"
+	}
+	v, err := refx.NewType(reflect.TypeOf((*HttpSigner)(nil)).Elem(), options, opts...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "refx.NewType failed")
+	}
+
+	return v.(HttpSigner), nil
+}
+
+// HttpSigner 在请求发送前对请求签名，body 为请求的 body
+type HttpSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// signerNow 签名使用的时间，测试时替换
+var signerNow = time.Now
+
+// signerNonce 签名使用的随机数，测试时替换
+var signerNonce = func() string {
+	return uuid.NewV4().String()
+}
+
+type BasicSignerOptions struct {
+	Username string
+	Password string
+}
+
+func NewBasicSignerWithOptions(options *BasicSignerOptions) (*BasicSigner, error) {
+	return &BasicSigner{options: options}, nil
+}
+
+type BasicSigner struct {
+	options *BasicSignerOptions
+}
+
+func (s *BasicSigner) Sign(req *http.Request, body []byte) error {
+	req.SetBasicAuth(s.options.Username, s.options.Password)
+	return nil
+}
+
+type BearerSignerOptions struct {
+	Token string
+}
+
+func NewBearerSignerWithOptions(options *BearerSignerOptions) (*BearerSigner, error) {
+	return &BearerSigner{options: options}, nil
+}
+
+type BearerSigner struct {
+	options *BearerSignerOptions
+}
+
+func (s *BearerSigner) Sign(req *http.Request, body []byte) error {
+	req.Header.Set("Authorization", "Bearer "+s.options.Token)
+	return nil
+}
+
+type HmacSignerOptions struct {
+	AccessKey string
+	Secret    string
+	// sha1/sha256/sha512，默认 sha256
+	Algorithm string
+	// 签名的编码 hex/base64，默认 hex
+	Encoding string
+	// 参与签名的 header，TimestampHeader 总是参与签名
+	SignedHeaders []string
+	// 默认为 X-Access-Key，X-Timestamp，X-Signature
+	AccessKeyHeader string
+	TimestampHeader string
+	SignatureHeader string
+}
+
+func NewHmacSignerWithOptions(options *HmacSignerOptions) (*HmacSigner, error) {
+	if options.Algorithm == "" {
+		options.Algorithm = "sha256"
+	}
+	if options.Encoding == "" {
+		options.Encoding = "hex"
+	}
+	if options.AccessKeyHeader == "" {
+		options.AccessKeyHeader = "X-Access-Key"
+	}
+	if options.TimestampHeader == "" {
+		options.TimestampHeader = "X-Timestamp"
+	}
+	if options.SignatureHeader == "" {
+		options.SignatureHeader = "X-Signature"
+	}
+
+	var hashFunc func() hash.Hash
+	switch options.Algorithm {
+	case "sha1":
+		hashFunc = sha1.New
+	case "sha256":
+		hashFunc = sha256.New
+	case "sha512":
+		hashFunc = sha512.New
+	default:
+		return nil, errors.Errorf("unsupported algorithm [%s]", options.Algorithm)
+	}
+	switch options.Encoding {
+	case "hex", "base64":
+	default:
+		return nil, errors.Errorf("unsupported encoding [%s]", options.Encoding)
+	}
+
+	return &HmacSigner{
+		options:       options,
+		hashFunc:      hashFunc,
+		signedHeaders: append([]string{options.TimestampHeader}, options.SignedHeaders...),
+	}, nil
+}
+
+// HmacSigner 对 hmacStringToSign 生成的字符串签名，AccessKey，时间戳和签名放在 header 中
+type HmacSigner struct {
+	options       *HmacSignerOptions
+	hashFunc      func() hash.Hash
+	signedHeaders []string
+}
+
+func (s *HmacSigner) Sign(req *http.Request, body []byte) error {
+	req.Header.Set(s.options.AccessKeyHeader, s.options.AccessKey)
+	req.Header.Set(s.options.TimestampHeader, fmt.Sprintf("%d", signerNow().Unix()))
+
+	h := hmac.New(s.hashFunc, []byte(s.options.Secret))
+	h.Write([]byte(hmacStringToSign(req, body, s.signedHeaders)))
+	if s.options.Encoding == "base64" {
+		req.Header.Set(s.options.SignatureHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	} else {
+		req.Header.Set(s.options.SignatureHeader, hex.EncodeToString(h.Sum(nil)))
+	}
+	return nil
+}
+
+// hmacStringToSign 依次为 method，path，按 key 排序的 query，按名字排序的 name:value 格式的 header，hex 编码的 body 的 sha256，以换行分隔
+func hmacStringToSign(req *http.Request, body []byte, signedHeaders []string) string {
+	var headers []string
+	for _, key := range signedHeaders {
+		headers = append(headers, strings.ToLower(key)+":"+strings.TrimSpace(req.Header.Get(key)))
+	}
+	sort.Strings(headers)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		strings.Join(headers, "\n"),
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+type AwsV4SignerOptions struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// 默认 us-east-1
+	Region  string
+	Service string
+}
+
+func NewAwsV4SignerWithOptions(options *AwsV4SignerOptions) (*AwsV4Signer, error) {
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.Service == "" {
+		return nil, errors.New("Service is required")
+	}
+	return &AwsV4Signer{options: options}, nil
+}
+
+// AwsV4Signer AWS Signature Version 4，签名 host，content-type 以及 x-amz-* 的 header
+type AwsV4Signer struct {
+	options *AwsV4SignerOptions
+}
+
+func (s *AwsV4Signer) Sign(req *http.Request, body []byte) error {
+	now := signerNow().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.options.SessionToken)
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if s.options.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key := range req.Header {
+		name := strings.ToLower(key)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(strings.Fields(req.Header.Get(key)), " ")
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL, s.options.Service != "s3"),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.options.Region, s.options.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + s.options.SecretAccessKey)
+	for _, v := range []string{date, s.options.Region, s.options.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.options.AccessKeyID, scope, signedHeaders, signature,
+	))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsURIEncode 除了 A-Za-z0-9-_.~ 之外的字符都编码为 %XX
+func awsURIEncode(str string, encodeSlash bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%%%02X", c))
+	}
+	return buf.String()
+}
+
+// awsCanonicalURI 除 s3 之外的服务 path 需要编码两次
+func awsCanonicalURI(u *url.URL, doubleEncode bool) string {
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	path = awsURIEncode(path, false)
+	if doubleEncode {
+		path = awsURIEncode(path, false)
+	}
+	return path
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, vals := range query {
+		for _, val := range vals {
+			pairs = append(pairs, awsURIEncode(key, true)+"="+awsURIEncode(val, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+type AliyunSignerOptions struct {
+	AccessKeyId     string
+	AccessKeySecret string
+}
+
+func NewAliyunRpcSignerWithOptions(options *AliyunSignerOptions) (*AliyunRpcSigner, error) {
+	return &AliyunRpcSigner{options: options}, nil
+}
+
+// AliyunRpcSigner 阿里云 RPC 风格的签名，Action，Version 等参数由请求的 Params 指定
+type AliyunRpcSigner struct {
+	options *AliyunSignerOptions
+}
+
+func (s *AliyunRpcSigner) Sign(req *http.Request, body []byte) error {
+	query := req.URL.Query()
+	query.Set("AccessKeyId", s.options.AccessKeyId)
+	query.Set("SignatureMethod", "HMAC-SHA1")
+	query.Set("SignatureVersion", "1.0")
+	query.Set("SignatureNonce", signerNonce())
+	query.Set("Timestamp", signerNow().UTC().Format("2006-01-02T15:04:05Z"))
+	if query.Get("Format") == "" {
+		query.Set("Format", "JSON")
+	}
+	query.Del("Signature")
+
+	params := map[string]*string{}
+	for key := range query {
+		params[key] = tea.String(query.Get(key))
+	}
+	// 表单参数同样参与签名
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return errors.Wrap(err, "url.ParseQuery failed")
+		}
+		for key := range form {
+			params[key] = tea.String(form.Get(key))
+		}
+	}
+
+	query.Set("Signature", tea.StringValue(openapiutil.GetRPCSignature(params, tea.String(req.Method), tea.String(s.options.AccessKeySecret))))
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+func NewAliyunRoaSignerWithOptions(options *AliyunSignerOptions) (*AliyunRoaSigner, error) {
+	return &AliyunRoaSigner{options: options}, nil
+}
+
+// AliyunRoaSigner 阿里云 ROA 风格的签名，x-acs-version 等 header 由请求的 Headers 指定
+type AliyunRoaSigner struct {
+	options *AliyunSignerOptions
+}
+
+func (s *AliyunRoaSigner) Sign(req *http.Request, body []byte) error {
+	req.Header.Set("Date", signerNow().UTC().Format(http.TimeFormat))
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("X-Acs-Signature-Method", "HMAC-SHA1")
+	req.Header.Set("X-Acs-Signature-Nonce", signerNonce())
+	req.Header.Set("X-Acs-Signature-Version", "1.0")
+	if len(body) != 0 {
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	stringToSign := openapiutil.GetStringToSign(aliyunRoaTeaRequest(req))
+	signature := openapiutil.GetROASignature(stringToSign, tea.String(s.options.AccessKeySecret))
+	req.Header.Set("Authorization", "acs "+s.options.AccessKeyId+":"+tea.StringValue(signature))
+	return nil
+}
+
+// aliyunRoaTeaRequest 转换成 openapiutil 计算签名使用的请求，header 的 key 为小写
+func aliyunRoaTeaRequest(req *http.Request) *tea.Request {
+	treq := tea.NewRequest()
+	treq.Method = tea.String(req.Method)
+	treq.Pathname = tea.String(req.URL.Path)
+	for key := range req.Header {
+		treq.Headers[strings.ToLower(key)] = tea.String(req.Header.Get(key))
+	}
+	query := req.URL.Query()
+	for key := range query {
+		treq.Query[key] = tea.String(query.Get(key))
+	}
+	return treq
+}
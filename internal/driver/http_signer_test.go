@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHttpSigner(t *testing.T) {
+	Convey("TestHttpSigner", t, func() {
+		// 服务端校验 Basic 和 Bearer 认证，其他签名和固定的期望值比较
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok := false
+			switch r.URL.Path {
+			case "/basic":
+				username, password, _ := r.BasicAuth()
+				ok = username == "user" && password == "pass"
+			case "/bearer":
+				ok = r.Header.Get("Authorization") == "Bearer token"
+			}
+			if !ok {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		}))
+		defer server.Close()
+
+		do := func(path string, signer refx.TypeOptions, req map[string]interface{}) int {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "Http",
+				Options: &HttpDriverOptions{Signers: []refx.TypeOptions{signer}},
+			})
+			So(err, ShouldBeNil)
+			req["Method"] = "POST"
+			req["URL"] = server.URL + path
+			res, err := d.Do(req)
+			So(err, ShouldBeNil)
+			return int(res.(map[string]interface{})["Status"].(int64))
+		}
+
+		Convey("basic", func() {
+			So(do("/basic", refx.TypeOptions{Type: "Basic", Options: &BasicSignerOptions{Username: "user", Password: "pass"}}, map[string]interface{}{}), ShouldEqual, http.StatusOK)
+			So(do("/basic", refx.TypeOptions{Type: "Basic", Options: &BasicSignerOptions{Username: "user", Password: "wrong"}}, map[string]interface{}{}), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("bearer", func() {
+			So(do("/bearer", refx.TypeOptions{Type: "Bearer", Options: &BearerSignerOptions{Token: "token"}}, map[string]interface{}{}), ShouldEqual, http.StatusOK)
+		})
+
+		Convey("hmac", func() {
+			now := signerNow
+			signerNow = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+			defer func() { signerNow = now }()
+
+			// 期望的签名由独立的实现计算，待签名的字符串为
+			// POST\n/api/v1/items\na=1&b=2\ncontent-type:application/json\nx-timestamp:1440938160\n<hex(sha256(body))>
+			signer, err := NewHttpSignerWithOptions(&refx.TypeOptions{Type: "Hmac", Options: &HmacSignerOptions{
+				AccessKey:     "ak",
+				Secret:        "secret",
+				SignedHeaders: []string{"Content-Type"},
+			}})
+			So(err, ShouldBeNil)
+			body := []byte(`{"key":"val"}`)
+			req, err := http.NewRequest("POST", "http://example.com/api/v1/items?b=2&a=1", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Content-Type", "application/json")
+			So(signer.Sign(req, body), ShouldBeNil)
+			So(req.Header.Get("X-Access-Key"), ShouldEqual, "ak")
+			So(req.Header.Get("X-Timestamp"), ShouldEqual, "1440938160")
+			So(req.Header.Get("X-Signature"), ShouldEqual, "bb05b2d737aebd055abf07b3203fed31379310ac5cfb6f8ae53687e48bacf3a9")
+
+			signer, err = NewHttpSignerWithOptions(&refx.TypeOptions{Type: "Hmac", Options: &HmacSignerOptions{
+				AccessKey:       "ak",
+				Secret:          "secret",
+				Algorithm:       "sha1",
+				Encoding:        "base64",
+				SignatureHeader: "Authorization",
+			}})
+			So(err, ShouldBeNil)
+			req, err = http.NewRequest("GET", "http://example.com", nil)
+			So(err, ShouldBeNil)
+			So(signer.Sign(req, nil), ShouldBeNil)
+			So(req.Header.Get("Authorization"), ShouldEqual, "1VlAO/P0B90lAapE2iiHZru6y8g=")
+
+			_, err = NewHttpSignerWithOptions(&refx.TypeOptions{Type: "Hmac", Options: &HmacSignerOptions{Algorithm: "md4"}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("aliyun rpc", func() {
+			// 阿里云 RPC 签名文档中 DescribeRegions 的示例
+			now, nonce := signerNow, signerNonce
+			signerNow = func() time.Time { return time.Date(2016, 2, 23, 12, 46, 24, 0, time.UTC) }
+			signerNonce = func() string { return "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf" }
+			defer func() { signerNow, signerNonce = now, nonce }()
+
+			signer, err := NewHttpSignerWithOptions(&refx.TypeOptions{Type: "AliyunRpc", Options: &AliyunSignerOptions{AccessKeyId: "testid", AccessKeySecret: "testsecret"}})
+			So(err, ShouldBeNil)
+			req, err := http.NewRequest("GET", "http://ecs.aliyuncs.com/?Action=DescribeRegions&Version=2014-05-26&Format=XML", nil)
+			So(err, ShouldBeNil)
+			So(signer.Sign(req, nil), ShouldBeNil)
+			So(req.URL.Query().Get("Signature"), ShouldEqual, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=")
+
+			// 表单参数同样参与签名
+			req, err = http.NewRequest("POST", "http://ecs.aliyuncs.com/?Action=DescribeRegions&Version=2014-05-26&Format=XML", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			So(signer.Sign(req, []byte("RegionId=cn-hangzhou")), ShouldBeNil)
+			So(req.URL.Query().Get("Signature"), ShouldEqual, "RrI9ZH54pAF1Y4tyVMMXyhwE0ww=")
+		})
+
+		Convey("aliyun roa", func() {
+			now, nonce := signerNow, signerNonce
+			signerNow = func() time.Time { return time.Date(2016, 2, 23, 12, 46, 24, 0, time.UTC) }
+			signerNonce = func() string { return "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf" }
+			defer func() { signerNow, signerNonce = now, nonce }()
+
+			// 期望的签名由独立的实现计算，待签名的字符串为
+			// POST\napplication/json\n<base64(md5(body))>\napplication/json\nTue, 23 Feb 2016 12:46:24 GMT\n
+			// x-acs-signature-method:HMAC-SHA1\nx-acs-signature-nonce:<nonce>\nx-acs-signature-version:1.0\nx-acs-version:2015-12-15\n
+			// /clusters?dryRun=true&name=a
+			signer, err := NewHttpSignerWithOptions(&refx.TypeOptions{Type: "AliyunRoa", Options: &AliyunSignerOptions{AccessKeyId: "testid", AccessKeySecret: "testsecret"}})
+			So(err, ShouldBeNil)
+			req, err := http.NewRequest("POST", "http://cs.aliyuncs.com/clusters?name=a&dryRun=true", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Acs-Version", "2015-12-15")
+			So(signer.Sign(req, []byte(`{"name":"test"}`)), ShouldBeNil)
+			So(req.Header.Get("Content-MD5"), ShouldEqual, "K4lbbvqii4GChOXGlqGHmQ==")
+			So(req.Header.Get("Authorization"), ShouldEqual, "acs testid:k+cXoo8NLSEa7rnnSO02m7UdfFs=")
+		})
+
+		Convey("aws v4", func() {
+			// AWS Signature Version 4 测试集中的 get-vanilla 和 get-vanilla-query-order-key-case
+			now := signerNow
+			signerNow = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+			defer func() { signerNow = now }()
+
+			signer, err := NewHttpSignerWithOptions(&refx.TypeOptions{Type: "AwsV4", Options: &AwsV4SignerOptions{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				Region:          "us-east-1",
+				Service:         "service",
+			}})
+			So(err, ShouldBeNil)
+
+			req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+			So(signer.Sign(req, nil), ShouldBeNil)
+			So(req.Header.Get("Authorization"), ShouldEqual, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31")
+
+			req, _ = http.NewRequest("GET", "https://example.amazonaws.com/?"+url.Values{"Param2": {"value2"}, "Param1": {"value1"}}.Encode(), nil)
+			So(signer.Sign(req, nil), ShouldBeNil)
+			So(req.Header.Get("Authorization"), ShouldEqual, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500")
+		})
+	})
+}
@@ -0,0 +1,429 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/hatlonely/go-kit/refx"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/benv2/internal/eval"
+)
+
+func RegisterMiddleware(key string, constructor interface{}) {
+	refx.Register("driver.Middleware", key, constructor)
+}
+
+func NewMiddlewareWithOptions(options *refx.TypeOptions, opts ...refx.Option) (Middleware, error) {
+	if options.Namespace == "" {
+		options.Namespace = "driver.Middleware"
+	}
+	v, err := refx.NewType(reflect.TypeOf((*Middleware)(nil)).Elem(), options, opts...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "refx.NewType failed")
+	}
+
+	return v.(Middleware), nil
+}
+
+type Handler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// Middleware 包装驱动的请求，实现日志，限流，熔断等和具体驱动无关的功能
+type Middleware interface {
+	Wrap(next Handler) Handler
+}
+
+type MiddlewareDriverOptions struct {
+	Driver refx.TypeOptions
+	// 按顺序由外到内包装 Driver，第一个 Middleware 最先处理请求
+	Middleware []refx.TypeOptions
+}
+
+func NewMiddlewareDriverWithOptions(options *MiddlewareDriverOptions, opts ...refx.Option) (*MiddlewareDriver, error) {
+	d, err := NewDriverWithOptions(&options.Driver, opts...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "NewDriverWithOptions failed")
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return d.Do(req)
+	}
+	if cd, ok := d.(ContextDriver); ok {
+		handler = cd.DoContext
+	}
+	md := &MiddlewareDriver{driver: d}
+	for i := len(options.Middleware) - 1; i >= 0; i-- {
+		middleware, err := NewMiddlewareWithOptions(&options.Middleware[i], opts...)
+		if err != nil {
+			_ = md.Close()
+			return nil, errors.WithMessage(err, "NewMiddlewareWithOptions failed")
+		}
+		md.middlewares = append(md.middlewares, middleware)
+		handler = middleware.Wrap(handler)
+	}
+	md.handler = handler
+
+	return md, nil
+}
+
+// MiddlewareDriver 用 Middleware 包装任意注册的驱动
+type MiddlewareDriver struct {
+	driver      Driver
+	middlewares []Middleware
+	handler     Handler
+}
+
+func (d *MiddlewareDriver) Do(req interface{}) (interface{}, error) {
+	return d.DoContext(context.Background(), req)
+}
+
+func (d *MiddlewareDriver) DoContext(ctx context.Context, req interface{}) (interface{}, error) {
+	return d.handler(ctx, req)
+}
+
+// Close 关闭持有资源的 Middleware，如日志文件，以及被包装的驱动
+func (d *MiddlewareDriver) Close() error {
+	var err error
+	for _, middleware := range d.middlewares {
+		if closer, ok := middleware.(io.Closer); ok {
+			if e := closer.Close(); e != nil && err == nil {
+				err = errors.Wrap(e, "middleware.Close failed")
+			}
+		}
+	}
+	if e := Close(d.driver); e != nil && err == nil {
+		err = errors.WithMessage(e, "driver.Close failed")
+	}
+	return err
+}
+
+// sleepContext 等待 duration，ctx 结束时提前返回错误
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type LogMiddlewareOptions struct {
+	// 日志文件，为空时输出到标准输出
+	FilePath string
+	// 成功请求的采样率，失败的请求总是记录，为 0 时只记录失败的请求
+	SampleRate float64 `dft:"0.01"`
+}
+
+func NewLogMiddlewareWithOptions(options *LogMiddlewareOptions) (*LogMiddleware, error) {
+	m := &LogMiddleware{options: options, writer: os.Stdout}
+	if options.FilePath != "" {
+		fp, err := os.OpenFile(options.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.Wrap(err, "os.OpenFile failed")
+		}
+		m.writer = fp
+		m.fp = fp
+	}
+	return m, nil
+}
+
+// LogMiddleware 每个请求输出一行 json 日志
+type LogMiddleware struct {
+	options *LogMiddlewareOptions
+	writer  io.Writer
+	// 输出到文件时在 Close 中关闭
+	fp    *os.File
+	mutex sync.Mutex
+}
+
+func (m *LogMiddleware) Close() error {
+	if m.fp == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.fp.Close()
+}
+
+type middlewareLog struct {
+	Time    string
+	TraceID string `json:",omitempty"`
+	Req     interface{}
+	Res     interface{}
+	Err     string `json:",omitempty"`
+	ResTime time.Duration
+}
+
+func (m *LogMiddleware) Wrap(next Handler) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		now := time.Now()
+		res, err := next(ctx, req)
+		if err == nil && rand.Float64() >= m.options.SampleRate {
+			return res, err
+		}
+
+		log := &middlewareLog{Time: now.Format(time.RFC3339Nano), Req: req, Res: res, ResTime: time.Since(now)}
+		if trace := TraceFromContext(ctx); trace != nil {
+			log.TraceID = trace.ID
+		}
+		if err != nil {
+			log.Err = err.Error()
+		}
+		buf, e := jsoniter.Marshal(log)
+		if e != nil {
+			return res, err
+		}
+		m.mutex.Lock()
+		_, _ = m.writer.Write(append(buf, '\n'))
+		m.mutex.Unlock()
+		return res, err
+	}
+}
+
+type RateLimitMiddlewareOptions struct {
+	QPS   float64
+	Burst int `dft:"1"`
+}
+
+func NewRateLimitMiddlewareWithOptions(options *RateLimitMiddlewareOptions) (*RateLimitMiddleware, error) {
+	if options.QPS <= 0 {
+		return nil, errors.New("QPS should be positive")
+	}
+	if options.Burst <= 0 {
+		options.Burst = 1
+	}
+	return &RateLimitMiddleware{
+		options: options,
+		tokens:  float64(options.Burst),
+		last:    time.Now(),
+	}, nil
+}
+
+// RateLimitMiddleware 令牌桶限流，令牌不足时等待，等待时间计入响应时间
+type RateLimitMiddleware struct {
+	options *RateLimitMiddlewareOptions
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve 预占一个令牌，返回需要等待的时间
+func (m *RateLimitMiddleware) reserve() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	m.tokens += now.Sub(m.last).Seconds() * m.options.QPS
+	if m.tokens > float64(m.options.Burst) {
+		m.tokens = float64(m.options.Burst)
+	}
+	m.last = now
+	m.tokens--
+	if m.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-m.tokens / m.options.QPS * float64(time.Second))
+}
+
+func (m *RateLimitMiddleware) Wrap(next Handler) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		if err := sleepContext(ctx, m.reserve()); err != nil {
+			// 请求取消时归还令牌
+			m.mutex.Lock()
+			m.tokens++
+			m.mutex.Unlock()
+			return nil, errors.Wrap(err, "wait for token failed")
+		}
+		return next(ctx, req)
+	}
+}
+
+type ConcurrencyMiddlewareOptions struct {
+	MaxConcurrency int
+	// 等待的最长时间，超过后返回 middleware.ConcurrencyLimited，为 0 时一直等待
+	Timeout time.Duration
+}
+
+func NewConcurrencyMiddlewareWithOptions(options *ConcurrencyMiddlewareOptions) (*ConcurrencyMiddleware, error) {
+	if options.MaxConcurrency <= 0 {
+		return nil, errors.New("MaxConcurrency should be positive")
+	}
+	return &ConcurrencyMiddleware{options: options, sem: make(chan struct{}, options.MaxConcurrency)}, nil
+}
+
+// ConcurrencyMiddleware 限制同时执行的请求数
+type ConcurrencyMiddleware struct {
+	options *ConcurrencyMiddlewareOptions
+	sem     chan struct{}
+}
+
+func (m *ConcurrencyMiddleware) Wrap(next Handler) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		var timeout <-chan time.Time
+		if m.options.Timeout > 0 {
+			timer := time.NewTimer(m.options.Timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case m.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "wait for concurrency failed")
+		case <-timeout:
+			return nil, NewErrorf(nil, "middleware.ConcurrencyLimited", "no free slot in %v", m.options.Timeout)
+		}
+		defer func() { <-m.sem }()
+		return next(ctx, req)
+	}
+}
+
+type CircuitBreakerMiddlewareOptions struct {
+	// 连续失败的次数达到阈值后熔断
+	FailureThreshold int `dft:"5"`
+	// 熔断的时间，之后放行一个探测请求，探测成功后恢复，失败则继续熔断
+	OpenDuration time.Duration `dft:"10s"`
+	// 判断请求失败的表达式，变量 res 为驱动的返回，如 res.StatusCode >= 500，驱动返回错误时总是计为失败
+	Failure string
+}
+
+func NewCircuitBreakerMiddlewareWithOptions(options *CircuitBreakerMiddlewareOptions) (*CircuitBreakerMiddleware, error) {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = 5
+	}
+	if options.OpenDuration <= 0 {
+		options.OpenDuration = 10 * time.Second
+	}
+	m := &CircuitBreakerMiddleware{options: options}
+	if options.Failure != "" {
+		failure, err := eval.Lang.NewEvaluable(options.Failure)
+		if err != nil {
+			return nil, errors.Wrapf(err, "eval.Lang.NewEvaluable [%s] failed", options.Failure)
+		}
+		m.failure = failure
+	}
+	return m, nil
+}
+
+// CircuitBreakerMiddleware 熔断期间直接返回 middleware.CircuitOpen，不再请求下游
+type CircuitBreakerMiddleware struct {
+	options *CircuitBreakerMiddlewareOptions
+	failure gval.Evaluable
+
+	mutex    sync.Mutex
+	failures int
+	openTime time.Time
+	// 熔断时间结束后，是否已经有探测请求在执行
+	probing bool
+}
+
+// allow 返回请求是否可以执行，以及是否是探测请求
+func (m *CircuitBreakerMiddleware) allow() (bool, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.failures < m.options.FailureThreshold {
+		return true, false
+	}
+	if m.probing || time.Since(m.openTime) < m.options.OpenDuration {
+		return false, false
+	}
+	m.probing = true
+	return true, true
+}
+
+func (m *CircuitBreakerMiddleware) done(probe bool, failed bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if probe {
+		m.probing = false
+	}
+	if !failed {
+		m.failures = 0
+		return
+	}
+	m.failures++
+	if m.failures >= m.options.FailureThreshold {
+		m.openTime = time.Now()
+	}
+}
+
+func (m *CircuitBreakerMiddleware) Wrap(next Handler) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		ok, probe := m.allow()
+		if !ok {
+			return nil, NewError(nil, "middleware.CircuitOpen", "circuit breaker is open")
+		}
+		res, err := next(ctx, req)
+		// 请求被取消不是下游的问题，不计入失败
+		if err != nil && ctx.Err() != nil {
+			if probe {
+				m.mutex.Lock()
+				m.probing = false
+				m.mutex.Unlock()
+			}
+			return res, err
+		}
+		failed := err != nil
+		if !failed && m.failure != nil {
+			var e error
+			failed, e = m.failure.EvalBool(ctx, map[string]interface{}{"res": res})
+			if e != nil {
+				m.done(probe, true)
+				return nil, NewErrorf(e, "middleware.InvalidFailure", "evaluate Failure [%s] failed, err: [%s]", m.options.Failure, e.Error())
+			}
+		}
+		m.done(probe, failed)
+		return res, err
+	}
+}
+
+type FaultMiddlewareOptions struct {
+	// 注入延迟的概率，延迟在 [Delay, Delay+DelayJitter) 之间均匀分布
+	DelayRate   float64
+	Delay       time.Duration
+	DelayJitter time.Duration
+	// 注入错误的概率，注入错误的请求不会发送到下游
+	ErrorRate float64
+	ErrCode   string `dft:"middleware.FaultInjected"`
+}
+
+func NewFaultMiddlewareWithOptions(options *FaultMiddlewareOptions) (*FaultMiddleware, error) {
+	if options.ErrCode == "" {
+		options.ErrCode = "middleware.FaultInjected"
+	}
+	return &FaultMiddleware{options: options}, nil
+}
+
+// FaultMiddleware 故障注入，用于验证被测系统和压测脚本在延迟和错误下的表现
+type FaultMiddleware struct {
+	options *FaultMiddlewareOptions
+}
+
+func (m *FaultMiddleware) Wrap(next Handler) Handler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		if m.options.DelayRate > 0 && rand.Float64() < m.options.DelayRate {
+			delay := m.options.Delay
+			if m.options.DelayJitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(m.options.DelayJitter)))
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, errors.Wrap(err, "inject delay failed")
+			}
+		}
+		if m.options.ErrorRate > 0 && rand.Float64() < m.options.ErrorRate {
+			return nil, NewError(nil, m.options.ErrCode, "fault injected")
+		}
+		return next(ctx, req)
+	}
+}
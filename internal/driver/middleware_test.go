@@ -0,0 +1,248 @@
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatlonely/go-kit/refx"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddlewareDriver(t *testing.T) {
+	Convey("TestMiddlewareDriver", t, func() {
+		_ = os.RemoveAll("middleware.log")
+		defer os.RemoveAll("middleware.log")
+
+		d, err := NewDriverWithOptions(&refx.TypeOptions{
+			Type: "Middleware",
+			Options: &MiddlewareDriverOptions{
+				Driver: refx.TypeOptions{Type: "Shell", Options: &ShellDriverOptions{}},
+				Middleware: []refx.TypeOptions{
+					{Type: "Log", Options: &LogMiddlewareOptions{FilePath: "middleware.log"}},
+					{Type: "Fault", Options: &FaultMiddlewareOptions{ErrorRate: 1}},
+				},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		ctx := WithTrace(context.Background(), &Trace{ID: "abc"})
+		_, err = d.(ContextDriver).DoContext(ctx, map[string]interface{}{"Command": "echo -n hello"})
+		So(errors.Cause(err).(*Error).Code, ShouldEqual, "middleware.FaultInjected")
+
+		buf, err := ioutil.ReadFile("middleware.log")
+		So(err, ShouldBeNil)
+		var log map[string]interface{}
+		So(jsoniter.Unmarshal(buf, &log), ShouldBeNil)
+		So(log["TraceID"], ShouldEqual, "abc")
+		So(log["Err"], ShouldContainSubstring, "fault injected")
+		So(log["Req"], ShouldResemble, map[string]interface{}{"Command": "echo -n hello"})
+
+		Convey("without middleware", func() {
+			d, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "Middleware",
+				Options: &MiddlewareDriverOptions{Driver: refx.TypeOptions{Type: "Shell", Options: &ShellDriverOptions{}}},
+			})
+			So(err, ShouldBeNil)
+			res, err := d.Do(map[string]interface{}{"Command": "echo -n hello"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Stdout"], ShouldEqual, "hello")
+		})
+
+		Convey("close", func() {
+			m := d.(*MiddlewareDriver).middlewares[1].(*LogMiddleware)
+			So(Close(d), ShouldBeNil)
+			_, err := m.fp.WriteString("\n")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("pass refx options to the inner driver", func() {
+			var received []refx.Option
+			RegisterDriver("TestOptions", func(options *MockDriverOptions, opts ...refx.Option) (*MockDriver, error) {
+				received = opts
+				return NewMockDriverWithOptions(options)
+			})
+			_, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type:    "Middleware",
+				Options: &MiddlewareDriverOptions{Driver: refx.TypeOptions{Type: "TestOptions", Options: &MockDriverOptions{}}},
+			}, refx.WithCamelName())
+			So(err, ShouldBeNil)
+			So(received, ShouldHaveLength, 1)
+		})
+
+		Convey("unknown middleware", func() {
+			_, err := NewDriverWithOptions(&refx.TypeOptions{
+				Type: "Middleware",
+				Options: &MiddlewareDriverOptions{
+					Driver:     refx.TypeOptions{Type: "Shell", Options: &ShellDriverOptions{}},
+					Middleware: []refx.TypeOptions{{Type: "Unknown"}},
+				},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	Convey("TestMiddleware", t, func() {
+		var calls int32
+		ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return req, nil
+		}
+		fail := func(ctx context.Context, req interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, NewError(nil, "Fail", "fail")
+		}
+
+		Convey("log sample", func() {
+			_ = os.RemoveAll("middleware.log")
+			defer os.RemoveAll("middleware.log")
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "Log", Options: &LogMiddlewareOptions{FilePath: "middleware.log", SampleRate: 0.000001}})
+			So(err, ShouldBeNil)
+			for i := 0; i < 10; i++ {
+				_, _ = m.Wrap(ok)(context.Background(), i)
+			}
+			_, _ = m.Wrap(fail)(context.Background(), 10)
+			buf, _ := ioutil.ReadFile("middleware.log")
+			So(strings.Count(string(buf), "\n"), ShouldEqual, 1)
+		})
+
+		Convey("rate limit", func() {
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "RateLimit", Options: &RateLimitMiddlewareOptions{QPS: 50, Burst: 5}})
+			So(err, ShouldBeNil)
+			handler := m.Wrap(ok)
+			now := time.Now()
+			for i := 0; i < 10; i++ {
+				_, err := handler(context.Background(), i)
+				So(err, ShouldBeNil)
+			}
+			// 前 5 个请求使用 burst，后 5 个请求每个等待 20ms
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+			So(time.Since(now), ShouldBeLessThan, 300*time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+			defer cancel()
+			_, err = handler(ctx, 0)
+			So(errors.Cause(err), ShouldResemble, context.DeadlineExceeded)
+
+			_, err = NewMiddlewareWithOptions(&refx.TypeOptions{Type: "RateLimit", Options: &RateLimitMiddlewareOptions{}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("concurrency", func() {
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "Concurrency", Options: &ConcurrencyMiddlewareOptions{MaxConcurrency: 2, Timeout: 20 * time.Millisecond}})
+			So(err, ShouldBeNil)
+			var running, maxRunning int32
+			handler := m.Wrap(func(ctx context.Context, req interface{}) (interface{}, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return req, nil
+			})
+
+			var wg sync.WaitGroup
+			var limited int32
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := handler(context.Background(), 0); err != nil && errors.Cause(err).(*Error).Code == "middleware.ConcurrencyLimited" {
+						atomic.AddInt32(&limited, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			So(maxRunning, ShouldEqual, 2)
+			So(limited, ShouldEqual, 2)
+		})
+
+		Convey("circuit breaker", func() {
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "CircuitBreaker", Options: &CircuitBreakerMiddlewareOptions{FailureThreshold: 3, OpenDuration: 50 * time.Millisecond}})
+			So(err, ShouldBeNil)
+			for i := 0; i < 3; i++ {
+				_, err := m.Wrap(fail)(context.Background(), i)
+				So(errors.Cause(err).(*Error).Code, ShouldEqual, "Fail")
+			}
+			_, err = m.Wrap(ok)(context.Background(), 0)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "middleware.CircuitOpen")
+			So(calls, ShouldEqual, 3)
+
+			// 探测失败后继续熔断
+			time.Sleep(60 * time.Millisecond)
+			_, err = m.Wrap(fail)(context.Background(), 0)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "Fail")
+			_, err = m.Wrap(ok)(context.Background(), 0)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "middleware.CircuitOpen")
+
+			// 探测成功后恢复
+			time.Sleep(60 * time.Millisecond)
+			_, err = m.Wrap(ok)(context.Background(), 0)
+			So(err, ShouldBeNil)
+			_, err = m.Wrap(ok)(context.Background(), 0)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("circuit breaker failure expression", func() {
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "CircuitBreaker", Options: &CircuitBreakerMiddlewareOptions{
+				FailureThreshold: 2, OpenDuration: time.Minute, Failure: "res.StatusCode >= 500",
+			}})
+			So(err, ShouldBeNil)
+			status := func(code int) Handler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					return map[string]interface{}{"StatusCode": code}, nil
+				}
+			}
+			for i := 0; i < 3; i++ {
+				_, err := m.Wrap(status(404))(context.Background(), i)
+				So(err, ShouldBeNil)
+			}
+			for i := 0; i < 2; i++ {
+				res, err := m.Wrap(status(503))(context.Background(), i)
+				So(err, ShouldBeNil)
+				So(res, ShouldResemble, map[string]interface{}{"StatusCode": 503})
+			}
+			_, err = m.Wrap(status(200))(context.Background(), 0)
+			So(errors.Cause(err).(*Error).Code, ShouldEqual, "middleware.CircuitOpen")
+
+			_, err = NewMiddlewareWithOptions(&refx.TypeOptions{Type: "CircuitBreaker", Options: &CircuitBreakerMiddlewareOptions{Failure: "res.StatusCode >="}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("fault", func() {
+			m, err := NewMiddlewareWithOptions(&refx.TypeOptions{Type: "Fault", Options: &FaultMiddlewareOptions{DelayRate: 1, Delay: 20 * time.Millisecond, DelayJitter: 10 * time.Millisecond}})
+			So(err, ShouldBeNil)
+			now := time.Now()
+			res, err := m.Wrap(ok)(context.Background(), 1)
+			So(err, ShouldBeNil)
+			So(res, ShouldEqual, 1)
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+
+			m, err = NewMiddlewareWithOptions(&refx.TypeOptions{Type: "Fault", Options: &FaultMiddlewareOptions{ErrorRate: 0.5, ErrCode: "Injected"}})
+			So(err, ShouldBeNil)
+			calls = 0
+			injected := 0
+			for i := 0; i < 1000; i++ {
+				if _, err := m.Wrap(ok)(context.Background(), i); err != nil {
+					So(errors.Cause(err).(*Error).Code, ShouldEqual, "Injected")
+					injected++
+				}
+			}
+			So(injected, ShouldAlmostEqual, 500, 100)
+			So(calls, ShouldEqual, 1000-injected)
+		})
+	})
+}
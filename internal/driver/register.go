@@ -3,4 +3,27 @@ package driver
 func init() {
 	RegisterDriver("Shell", NewWrapDriverWithMethodName(NewShellDriverWithOptions, "Do"))
 	RegisterDriver("Http", NewWrapDriverWithMethodName(NewHttpDriverWithOptions, "Do"))
+	RegisterDriver("Grpc", NewWrapDriverWithMethodName(NewGrpcDriverWithOptions, "Do"))
+	RegisterDriver("Redis", NewWrapDriverWithMethodName(NewRedisDriverWithOptions, "Do"))
+	RegisterDriver("Sql", NewWrapDriverWithMethodName(NewSqlDriverWithOptions, "Do"))
+	RegisterDriver("WebSocket", NewWrapDriverWithMethodName(NewWebSocketDriverWithOptions, "Do"))
+	RegisterDriver("Socket", NewWrapDriverWithMethodName(NewSocketDriverWithOptions, "Do"))
+	RegisterDriver("CoProcess", NewWrapDriverWithMethodName(NewCoProcessDriverWithOptions, "Do"))
+	RegisterDriver("AliyunOpenAPI", NewWrapDriverWithMethodName(NewAliyunOpenAPIDriverWithOptions, "Do"))
+	RegisterDriver("Mock", NewMockDriverWithOptions)
+	RegisterDriver("Middleware", NewMiddlewareDriverWithOptions)
+	RegisterDriver("LoadBalance", NewLoadBalanceDriverWithOptions)
+
+	RegisterHttpSigner("Basic", NewBasicSignerWithOptions)
+	RegisterHttpSigner("Bearer", NewBearerSignerWithOptions)
+	RegisterHttpSigner("Hmac", NewHmacSignerWithOptions)
+	RegisterHttpSigner("AwsV4", NewAwsV4SignerWithOptions)
+	RegisterHttpSigner("AliyunRpc", NewAliyunRpcSignerWithOptions)
+	RegisterHttpSigner("AliyunRoa", NewAliyunRoaSignerWithOptions)
+
+	RegisterMiddleware("Log", NewLogMiddlewareWithOptions)
+	RegisterMiddleware("RateLimit", NewRateLimitMiddlewareWithOptions)
+	RegisterMiddleware("Concurrency", NewConcurrencyMiddlewareWithOptions)
+	RegisterMiddleware("CircuitBreaker", NewCircuitBreakerMiddlewareWithOptions)
+	RegisterMiddleware("Fault", NewFaultMiddlewareWithOptions)
 }
@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"context"
+	"sync"
+)
+
+// Session 保存驱动跨请求的状态，如一个 unit 内的事务，一个 VU 的连接，由框架在 unit 或者 VU 结束时调用 Close 释放
+type Session struct {
+	mutex   sync.Mutex
+	values  map[interface{}]interface{}
+	closers map[interface{}]func()
+}
+
+func NewSession() *Session {
+	return &Session{
+		values:  map[interface{}]interface{}{},
+		closers: map[interface{}]func(){},
+	}
+}
+
+func (s *Session) Get(key interface{}) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set 保存状态，closer 在 Session 关闭时调用，可以为 nil
+func (s *Session) Set(key interface{}, val interface{}, closer func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = val
+	if closer != nil {
+		s.closers[key] = closer
+	} else {
+		delete(s.closers, key)
+	}
+}
+
+// Delete 删除状态，不会调用 closer
+func (s *Session) Delete(key interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.values, key)
+	delete(s.closers, key)
+}
+
+func (s *Session) Close() {
+	s.mutex.Lock()
+	closers := s.closers
+	s.values = map[interface{}]interface{}{}
+	s.closers = map[interface{}]func(){}
+	s.mutex.Unlock()
+
+	for _, closer := range closers {
+		closer()
+	}
+}
+
+type unitSessionKey struct{}
+
+func WithUnitSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, unitSessionKey{}, session)
+}
+
+// UnitSession 返回当前 unit 的 Session，不在框架中执行时返回 nil
+func UnitSession(ctx context.Context) *Session {
+	session, _ := ctx.Value(unitSessionKey{}).(*Session)
+	return session
+}
+
+type vuSessionKey struct{}
+
+func WithVUSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, vuSessionKey{}, session)
+}
+
+// VUSession 返回当前 VU 的 Session，不在框架中执行时返回 nil
+func VUSession(ctx context.Context) *Session {
+	session, _ := ctx.Value(vuSessionKey{}).(*Session)
+	return session
+}
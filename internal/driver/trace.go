@@ -0,0 +1,25 @@
+package driver
+
+import (
+	"context"
+)
+
+// Trace 当前请求的追踪信息，由框架在执行 step 时放入 ctx，驱动可以将 ID 透传给服务端，方便和服务端的日志关联
+type Trace struct {
+	// 每次执行 unit 生成一个 ID，同一个 unit 的多个 step 共享
+	ID   string
+	Unit string
+	Step int
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext 返回 ctx 中的追踪信息，不在框架中执行时返回 nil
+func TraceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
@@ -56,6 +56,7 @@ func NewEvaluable(v interface{}) (*Evaluable, error) {
 			}
 
 			ev.variables[key[0:idx+1]+key[idx+2:]] = e
+			ev.exprs = append(ev.exprs, expr)
 		} else {
 			if err := refx.InterfaceSet(&ev.consts, key, val); err != nil {
 				return errors.WithMessage(err, "refx.InterfaceSet failed")
@@ -75,6 +76,12 @@ func NewEvaluable(v interface{}) (*Evaluable, error) {
 type Evaluable struct {
 	consts    interface{}
 	variables map[string]gval.Evaluable
+	exprs     []string
+}
+
+// Exprs 返回所有表达式的原文
+func (e *Evaluable) Exprs() []string {
+	return e.exprs
 }
 
 func (e *Evaluable) Evaluate(vals interface{}) (interface{}, error) {
@@ -1,7 +1,9 @@
 package eval
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/hatlonely/go-kit/strx"
 	. "github.com/smartystreets/goconvey/convey"
@@ -135,3 +137,50 @@ func BenchmarkDeepCopy(b *testing.B) {
 		}
 	})
 }
+
+func TestNewDurationEvaluable(t *testing.T) {
+	Convey("TestNewDurationEvaluable", t, func() {
+		Convey("fixed", func() {
+			e, err := NewDurationEvaluable("100ms")
+			So(err, ShouldBeNil)
+			d, err := EvalDuration(context.Background(), e, nil)
+			So(err, ShouldBeNil)
+			So(d, ShouldEqual, 100*time.Millisecond)
+		})
+
+		Convey("uniform", func() {
+			e, err := NewDurationEvaluable(`uniform("50ms", "150ms")`)
+			So(err, ShouldBeNil)
+			for i := 0; i < 100; i++ {
+				d, err := EvalDuration(context.Background(), e, nil)
+				So(err, ShouldBeNil)
+				So(d, ShouldBeBetweenOrEqual, 50*time.Millisecond, 150*time.Millisecond)
+			}
+		})
+
+		Convey("exponential", func() {
+			e, err := NewDurationEvaluable(`exponential("100ms")`)
+			So(err, ShouldBeNil)
+			var total time.Duration
+			for i := 0; i < 10000; i++ {
+				d, err := EvalDuration(context.Background(), e, nil)
+				So(err, ShouldBeNil)
+				So(d, ShouldBeGreaterThanOrEqualTo, 0)
+				total += d
+			}
+			So(total/10000, ShouldAlmostEqual, 100*time.Millisecond, 10*time.Millisecond)
+		})
+
+		Convey("expression", func() {
+			e, err := NewDurationEvaluable(`var.thinkTime`)
+			So(err, ShouldBeNil)
+			d, err := EvalDuration(context.Background(), e, map[string]interface{}{
+				"var": map[string]interface{}{
+					"thinkTime": "2s",
+				},
+			})
+			So(err, ShouldBeNil)
+			So(d, ShouldEqual, 2*time.Second)
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -9,7 +10,9 @@ import (
 	"github.com/PaesslerAG/gval"
 	"github.com/generikvault/gvalstrings"
 	"github.com/hatlonely/go-kit/cast"
+	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
+	scast "github.com/spf13/cast"
 )
 
 var Lang = gval.NewLanguage(
@@ -69,4 +72,54 @@ var Lang = gval.NewLanguage(
 	gval.Function("randInt", func(n int64) (int64, error) {
 		return rand.Int63n(n), nil
 	}),
+	// uniform("50ms", "150ms") 返回 [min, max) 之间均匀分布的时长
+	gval.Function("uniform", func(min interface{}, max interface{}) (time.Duration, error) {
+		minD, err := scast.ToDurationE(min)
+		if err != nil {
+			return 0, errors.Wrap(err, "cast.ToDurationE failed")
+		}
+		maxD, err := scast.ToDurationE(max)
+		if err != nil {
+			return 0, errors.Wrap(err, "cast.ToDurationE failed")
+		}
+		if maxD <= minD {
+			return minD, nil
+		}
+		return minD + time.Duration(rand.Int63n(int64(maxD-minD))), nil
+	}),
+	// exponential("100ms") 返回均值为 mean 的指数分布的时长
+	gval.Function("exponential", func(mean interface{}) (time.Duration, error) {
+		meanD, err := scast.ToDurationE(mean)
+		if err != nil {
+			return 0, errors.Wrap(err, "cast.ToDurationE failed")
+		}
+		return time.Duration(rand.ExpFloat64() * float64(meanD)), nil
+	}),
 )
+
+// NewDurationEvaluable 解析时长表达式，可以是常量，如 "100ms"，也可以是表达式，如 `uniform("50ms", "150ms")`
+func NewDurationEvaluable(expr string) (gval.Evaluable, error) {
+	if d, err := time.ParseDuration(expr); err == nil {
+		return func(ctx context.Context, parameter interface{}) (interface{}, error) {
+			return d, nil
+		}, nil
+	}
+
+	e, err := Lang.NewEvaluable(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Lang.NewEvaluable failed")
+	}
+	return e, nil
+}
+
+func EvalDuration(ctx context.Context, e gval.Evaluable, parameter interface{}) (time.Duration, error) {
+	v, err := e(ctx, parameter)
+	if err != nil {
+		return 0, errors.Wrap(err, "evaluable failed")
+	}
+	d, err := scast.ToDurationE(v)
+	if err != nil {
+		return 0, errors.Wrap(err, "cast.ToDurationE failed")
+	}
+	return d, nil
+}
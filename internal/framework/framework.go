@@ -3,16 +3,23 @@ package framework
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PaesslerAG/gval"
 	"github.com/hatlonely/go-kit/refx"
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 
 	"github.com/hatlonely/benv2/internal/driver"
 	"github.com/hatlonely/benv2/internal/eval"
 	"github.com/hatlonely/benv2/internal/monitor"
+	"github.com/hatlonely/benv2/internal/plugin"
 	"github.com/hatlonely/benv2/internal/recorder"
 	"github.com/hatlonely/benv2/internal/reporter"
 	"github.com/hatlonely/benv2/internal/source"
@@ -25,16 +32,33 @@ type Options struct {
 	Ctx    map[string]refx.TypeOptions
 	Source map[string]refx.TypeOptions
 	Plan   struct {
+		// Duration 为 0 时不限制时长，阶段在达到迭代次数或者数据源耗尽时结束
 		Duration time.Duration
 		Interval time.Duration
+		// 每个阶段的迭代次数限制，Total 为所有 VU 的总次数，PerVU 为每个 VU 的次数，0 表示不限制
+		Iteration struct {
+			Total int
+			PerVU int
+		}
 		Parallel []map[string]int
-		Unit     []struct {
+		// Mix 中的 VU 共享一个并发池，每次迭代按权重选择 unit，Parallel 中可以像 unit 一样引用 mix 名
+		Mix []struct {
+			Name   string
+			Weight map[string]int
+		}
+		Unit []struct {
 			Name string
-			Step []*struct {
+			// 最小迭代周期，unit 执行完成后不足 Pacing 的部分会等待
+			Pacing time.Duration
+			Step   []*struct {
 				Ctx     string
 				Req     interface{}
 				ErrCode string
 				Success string
+				// 步骤执行完成后到下一个步骤开始前的等待时间，最后一个步骤没有 think time，如 "100ms"，`uniform("50ms", "150ms")`，`exponential("100ms")`
+				ThinkTime string
+				// 请求的超时时间，超时后取消请求，错误码为 Timeout，为 0 时不限制
+				Timeout time.Duration
 			}
 		}
 	}
@@ -43,10 +67,20 @@ type Options struct {
 	Statistics recorder.StatisticsOptions
 	Monitors   []refx.TypeOptions
 	Reporter   refx.TypeOptions
+	// 在创建 ctx，source 等之前加载，插件注册的实现可以像内置的实现一样使用
+	Plugins []plugin.Options
+	// 第一个阶段开始前对 ctx 的检查，key 为 ctx 名，所有 ctx 就绪后才开始压测
+	HealthCheck map[string]HealthCheckOptions
 }
 
 func NewFrameworkWithOptions(options *Options, opts ...refx.Option) (*Framework, error) {
 	var err error
+	for i := range options.Plugins {
+		if err := plugin.Load(&options.Plugins[i]); err != nil {
+			return nil, errors.WithMessage(err, "plugin.Load failed")
+		}
+	}
+
 	ctx := map[string]driver.Driver{}
 	for key, refxOptions := range options.Ctx {
 		ctx[key], err = driver.NewDriverWithOptions(&refxOptions, opts...)
@@ -55,6 +89,17 @@ func NewFrameworkWithOptions(options *Options, opts ...refx.Option) (*Framework,
 		}
 	}
 
+	healthCheck := map[string]*HealthCheckInfo{}
+	for key, healthCheckOptions := range options.HealthCheck {
+		if _, ok := ctx[key]; !ok {
+			return nil, errors.Errorf("ctx not found. health check: [%s]", key)
+		}
+		healthCheck[key], err = NewHealthCheckInfo(key, &healthCheckOptions)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewHealthCheckInfo failed")
+		}
+	}
+
 	source_ := map[string]source.Source{}
 	for key, refxOptions := range options.Source {
 		source_[key], err = source.NewSourceWithOptions(&refxOptions, opts...)
@@ -64,12 +109,17 @@ func NewFrameworkWithOptions(options *Options, opts ...refx.Option) (*Framework,
 	}
 
 	plan := &PlanInfo{
-		Duration: options.Plan.Duration,
-		Interval: options.Plan.Interval,
-		Parallel: options.Plan.Parallel,
+		Duration:       options.Plan.Duration,
+		Interval:       options.Plan.Interval,
+		IterationTotal: options.Plan.Iteration.Total,
+		IterationPerVU: options.Plan.Iteration.PerVU,
+		Parallel:       options.Plan.Parallel,
 	}
+	// step 中的表达式，用于判断引用了哪些数据源
+	var exprs []string
 	for _, unitDesc := range options.Plan.Unit {
 		var step []*StepInfo
+		var unitExprs []string
 		for _, stepDesc := range unitDesc.Step {
 			reqEval, err := eval.NewEvaluable(stepDesc.Req)
 			if err != nil {
@@ -89,18 +139,49 @@ func NewFrameworkWithOptions(options *Options, opts ...refx.Option) (*Framework,
 					return nil, errors.WithMessage(err, "eval.NewEvaluable failed")
 				}
 			}
+			var thinkTimeEval gval.Evaluable
+			if stepDesc.ThinkTime != "" {
+				thinkTimeEval, err = eval.NewDurationEvaluable(stepDesc.ThinkTime)
+				if err != nil {
+					return nil, errors.WithMessage(err, "eval.NewDurationEvaluable failed")
+				}
+			}
+			unitExprs = append(append(unitExprs, reqEval.Exprs()...), stepDesc.ThinkTime)
 			step = append(step, &StepInfo{
-				Ctx:     stepDesc.Ctx,
-				Req:     reqEval,
-				ErrCode: errCodeEval,
-				Success: successEval,
+				Ctx:       stepDesc.Ctx,
+				Req:       reqEval,
+				ErrCode:   errCodeEval,
+				Success:   successEval,
+				ThinkTime: thinkTimeEval,
+				Timeout:   stepDesc.Timeout,
 			})
 		}
+		exprs = append(exprs, unitExprs...)
 		plan.Unit = append(plan.Unit, &UnitInfo{
-			Name: unitDesc.Name,
-			Step: step,
+			Name:   unitDesc.Name,
+			Pacing: unitDesc.Pacing,
+			Step:   step,
+			Source: referencedSources(unitExprs),
 		})
 	}
+	if plan.Duration == 0 && plan.IterationTotal == 0 && plan.IterationPerVU == 0 {
+		finite := false
+		for key := range referencedSources(exprs) {
+			if src, ok := source_[key].(source.FiniteSource); ok && src.IsFinite() {
+				finite = true
+			}
+		}
+		if !finite {
+			return nil, errors.New("plan should stop on duration, iteration or a finite source referenced by steps")
+		}
+	}
+	for _, mixDesc := range options.Plan.Mix {
+		mix, err := NewMixInfo(mixDesc.Name, mixDesc.Weight, plan.Unit)
+		if err != nil {
+			return nil, errors.WithMessage(err, "NewMixInfo failed")
+		}
+		plan.Mix = append(plan.Mix, mix)
+	}
 
 	recorder_, err := recorder.NewRecorderWithOptions(&options.Recorder, opts...)
 	if err != nil {
@@ -135,54 +216,123 @@ func NewFrameworkWithOptions(options *Options, opts ...refx.Option) (*Framework,
 	}
 
 	return &Framework{
-		id:         options.ID,
-		name:       options.Name,
-		var_:       options.Var,
-		ctx:        ctx,
-		source:     source_,
-		plan:       plan,
-		recorder:   recorder_,
-		analyst:    analyst,
-		statistics: statistics,
-		monitors:   monitors,
-		reporter:   reporter_,
+		id:          options.ID,
+		name:        options.Name,
+		var_:        options.Var,
+		ctx:         ctx,
+		healthCheck: healthCheck,
+		source:      source_,
+		plan:        plan,
+		recorder:    recorder_,
+		analyst:     analyst,
+		statistics:  statistics,
+		monitors:    monitors,
+		reporter:    reporter_,
 	}, nil
 }
 
 type Framework struct {
-	id         string
-	name       string
-	var_       interface{}
-	ctx        map[string]driver.Driver
-	source     map[string]source.Source
-	plan       *PlanInfo
-	recorder   recorder.Recorder
-	analyst    recorder.Analyst
-	statistics *recorder.Statistics
-	monitors   []monitor.Monitor
-	reporter   reporter.Reporter
+	id          string
+	name        string
+	var_        interface{}
+	ctx         map[string]driver.Driver
+	healthCheck map[string]*HealthCheckInfo
+	source      map[string]source.Source
+	plan        *PlanInfo
+	recorder    recorder.Recorder
+	analyst     recorder.Analyst
+	statistics  *recorder.Statistics
+	monitors    []monitor.Monitor
+	reporter    reporter.Reporter
 }
 
 type PlanInfo struct {
-	Duration time.Duration
-	Interval time.Duration
-	Parallel []map[string]int
-	Unit     []*UnitInfo
+	Duration       time.Duration
+	Interval       time.Duration
+	IterationTotal int
+	IterationPerVU int
+	Parallel       []map[string]int
+	Unit           []*UnitInfo
+	Mix            []*MixInfo
 }
 
 type UnitInfo struct {
-	Name string
-	Step []*StepInfo
+	Name   string
+	Pacing time.Duration
+	Step   []*StepInfo
+	// step 中引用的数据源，unit 只从引用的有限数据源中读取数据
+	Source map[string]bool
+}
+
+type MixInfo struct {
+	Name   string
+	Weight map[string]int
+
+	unit  []*UnitInfo
+	bound []int
+}
+
+func NewMixInfo(name string, weight map[string]int, units []*UnitInfo) (*MixInfo, error) {
+	mix := &MixInfo{
+		Name:   name,
+		Weight: weight,
+	}
+
+	unitMap := map[string]*UnitInfo{}
+	for _, unit := range units {
+		unitMap[unit.Name] = unit
+	}
+	if _, ok := unitMap[name]; ok {
+		return nil, errors.Errorf("mix name conflicts with unit. mix: [%s]", name)
+	}
+	if len(weight) == 0 {
+		return nil, errors.Errorf("mix should have at least one unit. mix: [%s]", name)
+	}
+	for key, val := range weight {
+		if _, ok := unitMap[key]; !ok {
+			return nil, errors.Errorf("unit not found. mix: [%s], unit: [%s]", name, key)
+		}
+		// 统计中期望的占比按 Weight 计算，不能忽略非正数的权重
+		if val <= 0 {
+			return nil, errors.Errorf("weight should be positive. mix: [%s], unit: [%s], weight: [%d]", name, key, val)
+		}
+	}
+
+	total := 0
+	for _, unit := range units {
+		if _, ok := weight[unit.Name]; !ok {
+			continue
+		}
+		total += weight[unit.Name]
+		mix.unit = append(mix.unit, unit)
+		mix.bound = append(mix.bound, total)
+	}
+
+	return mix, nil
+}
+
+// Pick 按权重随机选择一个 unit
+func (m *MixInfo) Pick() *UnitInfo {
+	n := rand.Intn(m.bound[len(m.bound)-1])
+	return m.unit[sort.SearchInts(m.bound, n+1)]
 }
 
 type StepInfo struct {
-	Ctx     string
-	Req     *eval.Evaluable
-	ErrCode gval.Evaluable
-	Success gval.Evaluable
+	Ctx       string
+	Req       *eval.Evaluable
+	ErrCode   gval.Evaluable
+	Success   gval.Evaluable
+	ThinkTime gval.Evaluable
+	Timeout   time.Duration
 }
 
 func (fw *Framework) Run() error {
+	defer fw.Close()
+
+	if err := fw.HealthCheck(context.Background()); err != nil {
+		return errors.WithMessage(err, "fw.HealthCheck failed")
+	}
+
 	if err := fw.RunPlan(); err != nil {
 		return errors.WithMessage(err, "fw.RunPlan failed")
 	}
@@ -196,6 +346,21 @@ func (fw *Framework) Run() error {
 	return nil
 }
 
+// Close 释放 ctx 持有的连接，子进程等资源
+func (fw *Framework) Close() error {
+	var errs []string
+	for key, d := range fw.ctx {
+		if err := driver.Close(d); err != nil {
+			errs = append(errs, fmt.Sprintf("ctx [%s]: %v", key, err))
+		}
+	}
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return errors.Errorf("close ctx failed, %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 func (fw *Framework) Analyst() error {
 	metrics, err := fw.statistics.Statistics(fw.id, fw.analyst)
 	if err != nil {
@@ -235,58 +400,115 @@ func (fw *Framework) RunPlan() error {
 		Parallel: fw.plan.Parallel,
 		Duration: fw.plan.Duration,
 	}
+	for _, mix := range fw.plan.Mix {
+		if meta.Mix == nil {
+			meta.Mix = map[string]map[string]int{}
+		}
+		meta.Mix[mix.Name] = mix.Weight
+	}
 
 	startTime := time.Now().Add(time.Second)
 
 	for idx, parallelMap := range fw.plan.Parallel {
-		meta.TimeRange = append(meta.TimeRange, &recorder.TimeRange{
-			StartTime: startTime,
-			EndTime:   startTime.Add(fw.plan.Duration),
-		})
+		timeRange := &recorder.TimeRange{StartTime: startTime}
+		meta.TimeRange = append(meta.TimeRange, timeRange)
 
 		var wg sync.WaitGroup
-		ctx, cancel := context.WithCancel(context.Background())
+		var iteration int64
+		// 数据源耗尽时关闭，VU 不再开始新的迭代，正在执行的请求继续执行
+		exhausted := make(chan struct{})
+		var exhaustedOnce sync.Once
+		// 阶段结束时取消 ctx，正在执行的请求会被中断
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if fw.plan.Duration > 0 {
+			ctx, cancel = context.WithDeadline(context.Background(), startTime.Add(fw.plan.Duration))
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		worker := func(idx int, mix string, next func() *UnitInfo) {
+			// VU 内跨 unit 的状态，如 WebSocket 连接，VU 结束时释放
+			session := driver.NewSession()
+			ctx := driver.WithVUSession(ctx, session)
+			time.Sleep(time.Until(startTime))
+		out:
+			for i := 0; ; i++ {
+				if fw.plan.IterationPerVU > 0 && i >= fw.plan.IterationPerVU {
+					break
+				}
+				if fw.plan.IterationTotal > 0 && atomic.AddInt64(&iteration, 1) > int64(fw.plan.IterationTotal) {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					break out
+				case <-exhausted:
+					break out
+				default:
+					unit := next()
+					iterationStart := time.Now()
+					stat, err := fw.RunUnit(ctx, unit)
+					if errors.Cause(err) == source.ErrExhausted {
+						exhaustedOnce.Do(func() { close(exhausted) })
+						break out
+					}
+					if err != nil {
+						fmt.Println(err)
+						cancel()
+						break
+					}
+					stat.Seq = idx
+					stat.Mix = mix
+					err = fw.recorder.Record(stat)
+					if err != nil {
+						fmt.Println(err)
+						cancel()
+						break
+					}
+					if wait := time.Until(iterationStart.Add(unit.Pacing)); wait > 0 {
+						select {
+						case <-ctx.Done():
+							break out
+						case <-exhausted:
+							break out
+						case <-time.After(wait):
+						}
+					}
+				}
+			}
+			session.Close()
+			wg.Done()
+		}
 		for _, unit := range fw.plan.Unit {
 			parallel, ok := parallelMap[unit.Name]
 			if !ok {
 				continue
 			}
+			next := func(unit *UnitInfo) func() *UnitInfo {
+				return func() *UnitInfo { return unit }
+			}(unit)
 			for i := 0; i < parallel; i++ {
 				wg.Add(1)
-				go func(unit *UnitInfo, idx int) {
-					time.Sleep(time.Until(startTime))
-					deadline := time.After(fw.plan.Duration)
-				out:
-					for {
-						select {
-						case <-ctx.Done():
-							break out
-						case <-deadline:
-							break out
-						default:
-							stat, err := fw.RunUnit(unit)
-							if err != nil {
-								fmt.Println(err)
-								cancel()
-								break
-							}
-							stat.Seq = idx
-							err = fw.recorder.Record(stat)
-							if err != nil {
-								fmt.Println(err)
-								cancel()
-								break
-							}
-						}
-					}
-					wg.Done()
-				}(unit, idx)
+				go worker(idx, "", next)
+			}
+		}
+		for _, mix := range fw.plan.Mix {
+			parallel, ok := parallelMap[mix.Name]
+			if !ok {
+				continue
+			}
+			for i := 0; i < parallel; i++ {
+				wg.Add(1)
+				go worker(idx, mix.Name, mix.Pick)
 			}
 		}
 		wg.Wait()
 		cancel()
 
-		startTime = startTime.Add(fw.plan.Duration + fw.plan.Interval)
+		// 阶段可能因为迭代次数或者数据源耗尽提前结束，记录实际的结束时间
+		timeRange.EndTime = time.Now()
+		startTime = timeRange.EndTime.Add(fw.plan.Interval)
 	}
 
 	if err := fw.recorder.RecordMeta(meta); err != nil {
@@ -298,21 +520,45 @@ func (fw *Framework) RunPlan() error {
 	return nil
 }
 
-func (fw *Framework) RunUnit(info *UnitInfo) (*recorder.UnitStat, error) {
+func (fw *Framework) RunUnit(ctx context.Context, info *UnitInfo) (*recorder.UnitStat, error) {
 	unitStat := &recorder.UnitStat{Name: info.Name, ID: fw.id}
 	var err error
 
 	// fetch source
 	sourceMap := map[string]interface{}{}
 	for key, src := range fw.source {
+		if finiteSource, ok := src.(source.FiniteSource); ok {
+			// 没有引用的数据源不读取，避免消耗其他 unit 的数据
+			if !info.Source[key] {
+				continue
+			}
+			sourceMap[key], err = finiteSource.Next()
+			if err != nil {
+				return nil, errors.WithMessage(err, "source.Next failed")
+			}
+			continue
+		}
 		sourceMap[key] = src.Fetch()
 	}
 
 	var req interface{}
 	var stepResTime time.Duration
+	// 请求因为 step 的超时被取消
+	var stepTimeout bool
+	// 执行请求的 ctx 和处理请求的 endpoint
+	var stepCtxName string
+	var stepEndpoint string
+	// think time 不计入 unit 的响应时间
+	var thinkTime time.Duration
+
+	// unit 内跨 step 的状态，如事务，unit 结束时释放
+	session := driver.NewSession()
+	defer session.Close()
+	ctx = driver.WithUnitSession(ctx, session)
+	traceID := uuid.NewV4().String()
 
 	unitStart := time.Now()
-	for _, step := range info.Step {
+	for i, step := range info.Step {
 		req, err = step.Req.Evaluate(map[string]interface{}{
 			"source": sourceMap,
 			"stat":   unitStat,
@@ -325,11 +571,26 @@ func (fw *Framework) RunUnit(info *UnitInfo) (*recorder.UnitStat, error) {
 		if !ok {
 			return nil, errors.Errorf("ctx not found. ctx: [%s]", step.Ctx)
 		}
+		stepCtxName = step.Ctx
 
+		stepCtx := driver.WithTrace(ctx, &driver.Trace{ID: traceID, Unit: info.Name, Step: i})
+		stepCtx, endpoint := driver.WithEndpoint(stepCtx)
+		cancel := func() {}
+		if step.Timeout > 0 {
+			stepCtx, cancel = context.WithTimeout(stepCtx, step.Timeout)
+		}
 		stepStart := time.Now()
 		var res interface{}
-		res, err = d.Do(req)
+		// 不支持 ctx 的驱动无法在超时时取消请求
+		if cd, ok := d.(driver.ContextDriver); ok {
+			res, err = cd.DoContext(stepCtx, req)
+		} else {
+			res, err = d.Do(req)
+		}
 		stepResTime = time.Since(stepStart)
+		stepTimeout = stepCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		stepEndpoint = *endpoint
+		cancel()
 		if err != nil {
 			err = errors.WithMessage(err, "driver.Do failed")
 			break
@@ -358,44 +619,140 @@ func (fw *Framework) RunUnit(info *UnitInfo) (*recorder.UnitStat, error) {
 			}
 		}
 
-		unitStat.Step = append(unitStat.Step, &recorder.StepStat{
-			Time:    time.Now().Format(time.RFC3339Nano),
-			Req:     req,
-			Res:     res,
-			Err:     "",
-			ResTime: stepResTime,
-			ErrCode: errCode,
-		})
+		stepStat := &recorder.StepStat{
+			Time:     time.Now().Format(time.RFC3339Nano),
+			Req:      req,
+			Res:      res,
+			Err:      "",
+			ResTime:  stepResTime,
+			ErrCode:  errCode,
+			Stream:   streamStat(res),
+			Timing:   stepTiming(res),
+			Ctx:      stepCtxName,
+			Endpoint: stepEndpoint,
+		}
+		unitStat.Step = append(unitStat.Step, stepStat)
 
 		if errCode != "" {
 			unitStat.ErrCode = errCode
-			unitStat.ResTime = time.Since(unitStart)
+			unitStat.ResTime = time.Since(unitStart) - thinkTime
 			unitStat.Time = time.Now().Format(time.RFC3339Nano)
 			return unitStat, nil
 		}
+
+		// think time 是到下一个步骤的间隔，最后一个步骤之后由 Pacing 控制
+		if step.ThinkTime != nil && i != len(info.Step)-1 {
+			stepStat.ThinkTime, err = eval.EvalDuration(context.Background(), step.ThinkTime, map[string]interface{}{
+				"source": sourceMap,
+				"stat":   unitStat,
+				"var":    fw.var_,
+			})
+			if err != nil {
+				return nil, errors.WithMessage(err, "step.ThinkTime.Evaluate failed")
+			}
+			thinkStart := time.Now()
+			select {
+			case <-ctx.Done():
+			case <-time.After(stepStat.ThinkTime):
+			}
+			thinkTime += time.Since(thinkStart)
+			// 阶段在 think time 期间结束，剩余的步骤不再执行
+			if ctx.Err() != nil {
+				unitStat.ErrCode = recorder.ErrCodeAborted
+				unitStat.Aborted = true
+				break
+			}
+		}
 	}
 
 	if err != nil {
 		stepStat := &recorder.StepStat{
-			Time:    time.Now().Format(time.RFC3339Nano),
-			Req:     req,
-			Res:     nil,
-			Err:     err.Error(),
-			ResTime: stepResTime,
-			ErrCode: "Internal",
+			Time:     time.Now().Format(time.RFC3339Nano),
+			Req:      req,
+			Res:      nil,
+			Err:      err.Error(),
+			ResTime:  stepResTime,
+			ErrCode:  "Internal",
+			Ctx:      stepCtxName,
+			Endpoint: stepEndpoint,
 		}
 
 		switch e := errors.Cause(err).(type) {
 		case *driver.Error:
 			stepStat.ErrCode = e.Code
 		}
+		if stepTimeout {
+			stepStat.ErrCode = driver.ErrCodeTimeout
+		}
+
+		// 阶段结束导致请求被取消
+		if ctx.Err() != nil {
+			stepStat.ErrCode = recorder.ErrCodeAborted
+			unitStat.Aborted = true
+		}
 
 		unitStat.Step = append(unitStat.Step, stepStat)
 		unitStat.ErrCode = stepStat.ErrCode
 	}
 
-	unitStat.ResTime = time.Since(unitStart)
+	unitStat.ResTime = time.Since(unitStart) - thinkTime
 	unitStat.Time = time.Now().Format(time.RFC3339Nano)
 
 	return unitStat, nil
 }
+
+// streamStat 从驱动返回结果的 Stream 字段中获取流式请求的统计
+func streamStat(res interface{}) *recorder.StreamStat {
+	m, ok := res.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch stream := m["Stream"].(type) {
+	case *recorder.StreamStat:
+		return stream
+	case map[string]interface{}:
+		toInt64 := func(key string) int64 {
+			v, _ := stream[key].(int64)
+			return v
+		}
+		return &recorder.StreamStat{
+			FirstMessageTime: time.Duration(toInt64("FirstMessageTime")),
+			Duration:         time.Duration(toInt64("Duration")),
+			MessageCount:     int(toInt64("MessageCount")),
+			MaxMessageGap:    time.Duration(toInt64("MaxMessageGap")),
+		}
+	}
+	return nil
+}
+
+// stepTiming 从驱动返回结果的 Timing 字段中获取请求各个阶段的耗时
+func stepTiming(res interface{}) map[string]time.Duration {
+	m, ok := res.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	phases, ok := m["Timing"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	timing := map[string]time.Duration{}
+	for phase, v := range phases {
+		if d, ok := v.(int64); ok {
+			timing[phase] = time.Duration(d)
+		}
+	}
+	return timing
+}
+
+var sourceRefRegex = regexp.MustCompile(`(?:^|[^\w.])source\s*(?:\.\s*(\w+)|\[\s*"([^"]*)"\s*\])`)
+
+// referencedSources 返回表达式中通过 source.<name> 或者 source["<name>"] 引用的数据源
+func referencedSources(exprs []string) map[string]bool {
+	refs := map[string]bool{}
+	for _, expr := range exprs {
+		for _, match := range sourceRefRegex.FindAllStringSubmatch(expr, -1) {
+			refs[match[1]+match[2]] = true
+		}
+	}
+	return refs
+}
@@ -2,12 +2,20 @@ package framework
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/hatlonely/benv2/internal/driver"
+	"github.com/hatlonely/benv2/internal/eval"
+	"github.com/hatlonely/benv2/internal/recorder"
 	"github.com/hatlonely/go-kit/config"
 	"github.com/hatlonely/go-kit/refx"
 	"github.com/hatlonely/go-kit/strx"
@@ -120,6 +128,644 @@ func TestFramework_RunPlan(t *testing.T) {
 	})
 }
 
+func TestNewFrameworkWithOptions(t *testing.T) {
+	Convey("TestNewFrameworkWithOptions", t, func() {
+		_ = ioutil.WriteFile("test.source.json", []byte(`{"key1": "val1"}`), 0644)
+		defer os.RemoveAll("test.source.json")
+		defer os.RemoveAll("test.yaml")
+		defer os.RemoveAll("test.ben.json")
+
+		newOptions := func(once bool, expr string) *Options {
+			_ = ioutil.WriteFile("test.yaml", []byte(fmt.Sprintf(`
+ctx:
+  sh:
+    type: Shell
+    options: {}
+source:
+  src:
+    type: File
+    options:
+      filePath: test.source.json
+      once: %v
+  other:
+    type: Dict
+    options:
+      - key1: val1
+plan:
+  parallel:
+    - unit1: 1
+  unit:
+    - name: unit1
+      step:
+        - ctx: sh
+          req:
+            Command: echo
+            "#Args": '%s'
+recorder:
+  type: File
+  options:
+    filePath: test.ben.json
+`, once, expr)), 0644)
+			cfg, err := config.NewConfigWithSimpleFile("test.yaml", config.WithSimpleFileType("Yaml"))
+			So(err, ShouldBeNil)
+			var options Options
+			So(cfg.Unmarshal(&options, refx.WithCamelName()), ShouldBeNil)
+			return &options
+		}
+
+		Convey("referenced once source", func() {
+			_, err := NewFrameworkWithOptions(newOptions(true, "source.src.key1"), refx.WithCamelName())
+			So(err, ShouldBeNil)
+			_, err = NewFrameworkWithOptions(newOptions(true, `source["src"].key1`), refx.WithCamelName())
+			So(err, ShouldBeNil)
+		})
+
+		Convey("looping source", func() {
+			_, err := NewFrameworkWithOptions(newOptions(false, "source.src.key1"), refx.WithCamelName())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "plan should stop")
+		})
+
+		Convey("unreferenced once source", func() {
+			_, err := NewFrameworkWithOptions(newOptions(true, "source.other.key1"), refx.WithCamelName())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "plan should stop")
+		})
+	})
+}
+
+func TestFramework_RunUnit(t *testing.T) {
+	Convey("TestFramework_RunUnit", t, func() {
+		sh, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "Shell", Options: &driver.ShellDriverOptions{}})
+		So(err, ShouldBeNil)
+		fw := &Framework{ctx: map[string]driver.Driver{"sh": sh}}
+		newUnit := func(timeout time.Duration) *UnitInfo {
+			req, err := eval.NewEvaluable(map[string]interface{}{"Command": "sleep 10"})
+			So(err, ShouldBeNil)
+			return &UnitInfo{Name: "unit1", Step: []*StepInfo{{Ctx: "sh", Req: req, Timeout: timeout}}}
+		}
+
+		Convey("step timeout", func() {
+			now := time.Now()
+			stat, err := fw.RunUnit(context.Background(), newUnit(100*time.Millisecond))
+			So(err, ShouldBeNil)
+			So(time.Since(now), ShouldBeLessThan, 3*time.Second)
+			So(stat.ErrCode, ShouldEqual, driver.ErrCodeTimeout)
+			So(stat.Aborted, ShouldBeFalse)
+		})
+
+		Convey("think time", func() {
+			mock, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{}})
+			So(err, ShouldBeNil)
+			fw.ctx["mock"] = mock
+			req, err := eval.NewEvaluable(map[string]interface{}{})
+			So(err, ShouldBeNil)
+			thinkTime, err := eval.NewDurationEvaluable("500ms")
+			So(err, ShouldBeNil)
+			info := &UnitInfo{Name: "unit1", Step: []*StepInfo{
+				{Ctx: "mock", Req: req, ThinkTime: thinkTime},
+				{Ctx: "mock", Req: req, ThinkTime: thinkTime},
+			}}
+
+			now := time.Now()
+			stat, err := fw.RunUnit(context.Background(), info)
+			So(err, ShouldBeNil)
+			// 最后一个步骤之后不等待
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 500*time.Millisecond)
+			So(time.Since(now), ShouldBeLessThan, time.Second)
+			So(stat.Step[0].ThinkTime, ShouldEqual, 500*time.Millisecond)
+			So(stat.Step[1].ThinkTime, ShouldEqual, 0)
+			So(stat.ResTime, ShouldBeLessThan, 500*time.Millisecond)
+		})
+
+		Convey("endpoint", func() {
+			lb, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "LoadBalance", Options: &driver.LoadBalanceDriverOptions{
+				Endpoints: []driver.LoadBalanceEndpoint{
+					{Name: "replica1", Driver: refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{}}},
+					{Name: "replica2", Driver: refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{Errors: []driver.MockError{{Code: "Unavailable", Rate: 1}}}}},
+				},
+			}})
+			So(err, ShouldBeNil)
+			fw.ctx["lb"] = lb
+			req, err := eval.NewEvaluable(map[string]interface{}{})
+			So(err, ShouldBeNil)
+			info := &UnitInfo{Name: "unit1", Step: []*StepInfo{{Ctx: "lb", Req: req}}}
+
+			stat, err := fw.RunUnit(context.Background(), info)
+			So(err, ShouldBeNil)
+			So(stat.Step[0].Endpoint, ShouldEqual, "replica1")
+			stat, err = fw.RunUnit(context.Background(), info)
+			So(err, ShouldBeNil)
+			So(stat.Step[0].Endpoint, ShouldEqual, "replica2")
+			So(stat.ErrCode, ShouldEqual, "Unavailable")
+		})
+
+		Convey("stage canceled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			now := time.Now()
+			stat, err := fw.RunUnit(ctx, newUnit(0))
+			So(err, ShouldBeNil)
+			So(time.Since(now), ShouldBeLessThan, 3*time.Second)
+			So(stat.ErrCode, ShouldEqual, recorder.ErrCodeAborted)
+			So(stat.Aborted, ShouldBeTrue)
+			So(stat.Step, ShouldHaveLength, 1)
+			So(stat.Step[0].ErrCode, ShouldEqual, recorder.ErrCodeAborted)
+		})
+
+		Convey("stage canceled during think time", func() {
+			req, err := eval.NewEvaluable(map[string]interface{}{"Command": "echo -n hello"})
+			So(err, ShouldBeNil)
+			thinkTime, err := eval.NewDurationEvaluable("10s")
+			So(err, ShouldBeNil)
+			info := &UnitInfo{Name: "unit1", Step: []*StepInfo{
+				{Ctx: "sh", Req: req, ThinkTime: thinkTime},
+				{Ctx: "sh", Req: req},
+			}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			now := time.Now()
+			stat, err := fw.RunUnit(ctx, info)
+			So(err, ShouldBeNil)
+			So(time.Since(now), ShouldBeLessThan, 3*time.Second)
+			So(stat.ErrCode, ShouldEqual, recorder.ErrCodeAborted)
+			So(stat.Aborted, ShouldBeTrue)
+			// 剩余的步骤不再执行
+			So(stat.Step, ShouldHaveLength, 1)
+			So(stat.Step[0].ErrCode, ShouldEqual, "")
+		})
+	})
+}
+
+func TestFramework_EndpointStatistics(t *testing.T) {
+	Convey("TestFramework_EndpointStatistics", t, func() {
+		defer os.RemoveAll("test.ben.json")
+		defer os.RemoveAll("test.meta.json")
+
+		newLoadBalance := func() driver.Driver {
+			d, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "LoadBalance", Options: &driver.LoadBalanceDriverOptions{
+				Endpoints: []driver.LoadBalanceEndpoint{
+					{Driver: refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{}}},
+					{Driver: refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{}}},
+				},
+			}})
+			So(err, ShouldBeNil)
+			return d
+		}
+		recorder_, err := recorder.NewFileRecorderWithOptions(&recorder.FileRecorderOptions{FilePath: "test.ben.json", MetaPath: "test.meta.json", BufSize: 32768})
+		So(err, ShouldBeNil)
+		fw := &Framework{
+			ctx:        map[string]driver.Driver{"lb1": newLoadBalance(), "lb2": newLoadBalance()},
+			recorder:   recorder_,
+			statistics: recorder.NewStatisticsWithOptions(&recorder.StatisticsOptions{PointNumber: 10}),
+		}
+		req, err := eval.NewEvaluable(map[string]interface{}{})
+		So(err, ShouldBeNil)
+		// 两个 endpoint 名字相同的 LoadBalance，lb1 在一个 unit 中使用两次
+		info := &UnitInfo{Name: "unit1", Step: []*StepInfo{{Ctx: "lb1", Req: req}, {Ctx: "lb2", Req: req}, {Ctx: "lb1", Req: req}}}
+
+		startTime := time.Now()
+		for i := 0; i < 4; i++ {
+			stat, err := fw.RunUnit(context.Background(), info)
+			So(err, ShouldBeNil)
+			So(stat.Step[0].Ctx, ShouldEqual, "lb1")
+			So(stat.Step[1].Ctx, ShouldEqual, "lb2")
+			So(fw.recorder.Record(stat), ShouldBeNil)
+		}
+		So(fw.recorder.RecordMeta(&recorder.Meta{
+			Parallel:  []map[string]int{{"unit1": 1}},
+			TimeRange: []*recorder.TimeRange{{StartTime: startTime, EndTime: startTime.Add(time.Second)}},
+		}), ShouldBeNil)
+		So(fw.recorder.Close(), ShouldBeNil)
+
+		analyst, err := recorder.NewFileAnalystWithOptions(&recorder.FileAnalystOptions{FilePath: "test.ben.json", MetaPath: "test.meta.json"})
+		So(err, ShouldBeNil)
+		metrics, err := fw.statistics.Statistics("", analyst)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		endpoint := metrics[0].Endpoint["unit1"]
+		So(endpoint, ShouldHaveLength, 4)
+		So(endpoint["lb1/0"].Total, ShouldEqual, 4)
+		So(endpoint["lb1/1"].Total, ShouldEqual, 4)
+		So(endpoint["lb2/0"].Total, ShouldEqual, 2)
+		So(endpoint["lb2/1"].Total, ShouldEqual, 2)
+		So(endpoint["lb2/1"].SuccessRatePercent, ShouldEqual, 100)
+	})
+}
+
+func TestFramework_StatisticsEmptyStage(t *testing.T) {
+	Convey("TestFramework_StatisticsEmptyStage", t, func() {
+		defer os.RemoveAll("test.ben.json")
+		defer os.RemoveAll("test.meta.json")
+
+		recorder_, err := recorder.NewFileRecorderWithOptions(&recorder.FileRecorderOptions{FilePath: "test.ben.json", MetaPath: "test.meta.json", BufSize: 32768})
+		So(err, ShouldBeNil)
+		startTime := time.Now().Truncate(time.Second)
+		timeRange := []*recorder.TimeRange{
+			{StartTime: startTime, EndTime: startTime.Add(time.Second)},
+			{StartTime: startTime.Add(2 * time.Second), EndTime: startTime.Add(3 * time.Second)},
+			{StartTime: startTime.Add(4 * time.Second), EndTime: startTime.Add(5 * time.Second)},
+			{StartTime: startTime.Add(6 * time.Second), EndTime: startTime.Add(6 * time.Second)},
+		}
+		record := func(seq int, offset time.Duration, aborted bool) {
+			So(recorder_.Record(&recorder.UnitStat{
+				Seq:     seq,
+				Name:    "unit1",
+				Time:    timeRange[seq].StartTime.Add(offset).Format(time.RFC3339Nano),
+				ResTime: 10 * time.Millisecond,
+				Aborted: aborted,
+			}), ShouldBeNil)
+		}
+		// 第二个阶段没有记录，第三个阶段的记录都被中断，第四个阶段只有一条记录
+		record(0, 100*time.Millisecond, false)
+		record(0, 200*time.Millisecond, false)
+		record(2, 100*time.Millisecond, true)
+		record(3, 0, false)
+		So(recorder_.RecordMeta(&recorder.Meta{Parallel: []map[string]int{{"unit1": 1}}, TimeRange: timeRange}), ShouldBeNil)
+		So(recorder_.Close(), ShouldBeNil)
+
+		analyst, err := recorder.NewFileAnalystWithOptions(&recorder.FileAnalystOptions{FilePath: "test.ben.json", MetaPath: "test.meta.json"})
+		So(err, ShouldBeNil)
+		metrics, err := recorder.NewStatisticsWithOptions(&recorder.StatisticsOptions{PointNumber: 10}).Statistics("", analyst)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 4)
+		So(metrics[0].Summary["unit1"].Total, ShouldEqual, 2)
+		So(metrics[0].Summary["unit1"].SuccessRatePercent, ShouldEqual, 100)
+		So(metrics[1].Summary, ShouldBeEmpty)
+		So(metrics[2].Summary, ShouldBeEmpty)
+		summary := metrics[3].Summary["unit1"]
+		So(math.IsNaN(summary.QPS) || math.IsInf(summary.QPS, 0), ShouldBeFalse)
+		So(math.IsNaN(summary.AvgResTimeMs), ShouldBeFalse)
+		So(math.IsNaN(summary.SuccessRatePercent), ShouldBeFalse)
+	})
+}
+
+// runTestPlan 执行 playbook 中的计划，返回记录的 unit 和统计的指标
+func runTestPlan(playbook string) ([]*recorder.UnitStat, []*recorder.Metric) {
+	So(ioutil.WriteFile("test.yaml", []byte(playbook+`
+recorder:
+  type: File
+  options:
+    filePath: test.ben.json
+    metaPath: test.meta.json
+analyst:
+  type: File
+  options:
+    filePath: test.ben.json
+    metaPath: test.meta.json
+statistics:
+  pointNumber: 10
+`), 0644), ShouldBeNil)
+	cfg, err := config.NewConfigWithSimpleFile("test.yaml", config.WithSimpleFileType("Yaml"))
+	So(err, ShouldBeNil)
+	var options Options
+	So(cfg.Unmarshal(&options, refx.WithCamelName()), ShouldBeNil)
+	fw, err := NewFrameworkWithOptions(&options, refx.WithCamelName())
+	So(err, ShouldBeNil)
+	So(fw.RunPlan(), ShouldBeNil)
+	So(fw.Close(), ShouldBeNil)
+
+	fp, err := os.Open("test.ben.json")
+	So(err, ShouldBeNil)
+	defer fp.Close()
+	var stats []*recorder.UnitStat
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		var stat recorder.UnitStat
+		So(json.Unmarshal(scanner.Bytes(), &stat), ShouldBeNil)
+		stats = append(stats, &stat)
+	}
+	So(scanner.Err(), ShouldBeNil)
+
+	metrics, err := fw.statistics.Statistics(fw.id, fw.analyst)
+	So(err, ShouldBeNil)
+	return stats, metrics
+}
+
+func TestFramework_RunPlanMix(t *testing.T) {
+	Convey("TestFramework_RunPlanMix", t, func() {
+		defer os.RemoveAll("test.yaml")
+		defer os.RemoveAll("test.ben.json")
+		defer os.RemoveAll("test.meta.json")
+
+		stats, metrics := runTestPlan(`
+ctx:
+  mock:
+    type: Mock
+    options: {}
+plan:
+  iteration:
+    total: 2000
+  parallel:
+    - rw: 4
+  mix:
+    - name: rw
+      weight:
+        read: 3
+        write: 1
+  unit:
+    - name: read
+      step:
+        - ctx: mock
+          req: {}
+    - name: write
+      step:
+        - ctx: mock
+          req: {}
+    - name: delete
+      step:
+        - ctx: mock
+          req: {}
+`)
+		So(stats, ShouldHaveLength, 2000)
+		counts := map[string]int{}
+		mixes := map[string]int{}
+		for _, stat := range stats {
+			counts[stat.Name]++
+			mixes[stat.Mix]++
+		}
+		So(mixes, ShouldResemble, map[string]int{"rw": 2000})
+		So(counts["read"], ShouldAlmostEqual, 1500, 150)
+		So(counts["write"], ShouldAlmostEqual, 500, 150)
+		So(counts["delete"], ShouldEqual, 0)
+
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Mix["rw"], ShouldHaveLength, 2)
+		So(metrics[0].Mix["rw"]["read"].ExpectPercent, ShouldEqual, 75)
+		So(metrics[0].Mix["rw"]["write"].ExpectPercent, ShouldEqual, 25)
+		So(metrics[0].Mix["rw"]["read"].ActualPercent, ShouldAlmostEqual, 75, 7.5)
+		So(metrics[0].Mix["rw"]["write"].ActualPercent, ShouldAlmostEqual, 25, 7.5)
+	})
+}
+
+func TestFramework_RunPlanPacing(t *testing.T) {
+	Convey("TestFramework_RunPlanPacing", t, func() {
+		defer os.RemoveAll("test.yaml")
+		defer os.RemoveAll("test.ben.json")
+		defer os.RemoveAll("test.meta.json")
+
+		// unit 本身几乎不耗时，迭代周期由 Pacing 决定
+		stats, _ := runTestPlan(`
+ctx:
+  mock:
+    type: Mock
+    options: {}
+plan:
+  iteration:
+    perVU: 3
+  parallel:
+    - unit1: 1
+  unit:
+    - name: unit1
+      pacing: 300ms
+      step:
+        - ctx: mock
+          req: {}
+`)
+		So(stats, ShouldHaveLength, 3)
+		for i := 1; i < len(stats); i++ {
+			prev, err := time.Parse(time.RFC3339Nano, stats[i-1].Time)
+			So(err, ShouldBeNil)
+			curr, err := time.Parse(time.RFC3339Nano, stats[i].Time)
+			So(err, ShouldBeNil)
+			So(curr.Sub(prev), ShouldBeGreaterThanOrEqualTo, 250*time.Millisecond)
+		}
+
+		buf, err := ioutil.ReadFile("test.meta.json")
+		So(err, ShouldBeNil)
+		var meta recorder.Meta
+		So(json.Unmarshal(buf, &meta), ShouldBeNil)
+		So(meta.TimeRange, ShouldHaveLength, 1)
+		// 最后一次迭代之后同样等待 Pacing
+		So(meta.TimeRange[0].EndTime.Sub(meta.TimeRange[0].StartTime), ShouldBeGreaterThanOrEqualTo, 900*time.Millisecond)
+	})
+}
+
+func TestFramework_RunPlanOnceSource(t *testing.T) {
+	Convey("TestFramework_RunPlanOnceSource", t, func() {
+		defer os.RemoveAll("test.yaml")
+		defer os.RemoveAll("test.ben.json")
+		defer os.RemoveAll("test.meta.json")
+		defer os.RemoveAll("test.source.json")
+		defer os.RemoveAll("test.other.json")
+
+		var buf bytes.Buffer
+		for i := 0; i < 200; i++ {
+			buf.WriteString(fmt.Sprintf(`{"id": %d}`+"\n", i))
+		}
+		So(ioutil.WriteFile("test.source.json", buf.Bytes(), 0644), ShouldBeNil)
+		So(ioutil.WriteFile("test.other.json", []byte(`{"id": 0}`), 0644), ShouldBeNil)
+
+		// read 引用 src，write 不引用任何数据源，other 没有被引用，耗尽后不影响阶段结束
+		// src 在第一个阶段耗尽，第二个阶段立即结束
+		stats, metrics := runTestPlan(`
+ctx:
+  mock:
+    type: Mock
+    options:
+      latency:
+        mean: 5ms
+source:
+  src:
+    type: File
+    options:
+      filePath: test.source.json
+      once: true
+  other:
+    type: File
+    options:
+      filePath: test.other.json
+      once: true
+plan:
+  parallel:
+    - read: 4
+      rw: 4
+    - read: 2
+  mix:
+    - name: rw
+      weight:
+        read: 1
+        write: 1
+  unit:
+    - name: read
+      step:
+        - ctx: mock
+          req:
+            "#ID": source.src.id
+    - name: write
+      step:
+        - ctx: mock
+          req: {}
+`)
+		ids := map[float64]int{}
+		aborted := 0
+		for _, stat := range stats {
+			if stat.Aborted {
+				aborted++
+			}
+			if stat.Name == "read" {
+				ids[stat.Step[0].Req.(map[string]interface{})["ID"].(float64)]++
+			}
+		}
+		So(aborted, ShouldEqual, 0)
+		So(ids, ShouldHaveLength, 200)
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[1].Summary, ShouldBeEmpty)
+		for id, count := range ids {
+			if count != 1 {
+				So(fmt.Sprintf("id [%v] recorded %d times", id, count), ShouldBeEmpty)
+			}
+		}
+	})
+}
+
+func TestStreamStat(t *testing.T) {
+	Convey("TestStreamStat", t, func() {
+		So(streamStat(map[string]interface{}{"Stream": map[string]interface{}{
+			"FirstMessageTime": int64(10 * time.Millisecond),
+			"Duration":         int64(50 * time.Millisecond),
+			"MessageCount":     int64(3),
+			"MaxMessageGap":    int64(20 * time.Millisecond),
+		}}), ShouldResemble, &recorder.StreamStat{
+			FirstMessageTime: 10 * time.Millisecond,
+			Duration:         50 * time.Millisecond,
+			MessageCount:     3,
+			MaxMessageGap:    20 * time.Millisecond,
+		})
+
+		stat := &recorder.StreamStat{MessageCount: 1}
+		So(streamStat(map[string]interface{}{"Stream": stat}), ShouldEqual, stat)
+		So(streamStat(map[string]interface{}{}), ShouldBeNil)
+		So(streamStat("hello"), ShouldBeNil)
+	})
+}
+
+func TestFramework_HealthCheck(t *testing.T) {
+	Convey("TestFramework_HealthCheck", t, func() {
+		sh, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "Shell", Options: &driver.ShellDriverOptions{}})
+		So(err, ShouldBeNil)
+		mock, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{
+			Errors: []driver.MockError{{Code: "Unavailable", Rate: 1}},
+		}})
+		So(err, ShouldBeNil)
+		fw := &Framework{ctx: map[string]driver.Driver{"sh": sh, "mock": mock}, healthCheck: map[string]*HealthCheckInfo{}}
+
+		Convey("wait until ready", func() {
+			_ = os.RemoveAll("ready")
+			defer os.RemoveAll("ready")
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = ioutil.WriteFile("ready", nil, 0644)
+			}()
+			fw.healthCheck["sh"], err = NewHealthCheckInfo("sh", &HealthCheckOptions{
+				Req:      map[string]interface{}{"Command": "test -f ready"},
+				Success:  "res.ExitCode == 0",
+				Interval: 20 * time.Millisecond,
+				Timeout:  time.Second,
+			})
+			So(err, ShouldBeNil)
+			now := time.Now()
+			So(fw.HealthCheck(context.Background()), ShouldBeNil)
+			So(time.Since(now), ShouldBeGreaterThanOrEqualTo, 100*time.Millisecond)
+		})
+
+		Convey("not ready", func() {
+			fw.healthCheck["mock"], err = NewHealthCheckInfo("mock", &HealthCheckOptions{
+				Interval: 20 * time.Millisecond,
+				Timeout:  100 * time.Millisecond,
+			})
+			So(err, ShouldBeNil)
+			err := fw.HealthCheck(context.Background())
+			So(err, ShouldNotBeNil)
+			So(err.(*HealthCheckError).Ctx, ShouldEqual, "mock")
+			So(err.Error(), ShouldContainSubstring, "Unavailable")
+		})
+
+		Convey("attempt outlasts timeout", func() {
+			slow, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "Mock", Options: &driver.MockDriverOptions{
+				Latency: driver.MockLatencyOptions{Mean: 10 * time.Second},
+			}})
+			So(err, ShouldBeNil)
+			// 只暴露 Do，模拟不响应 ctx 的驱动
+			fw.ctx["slow"] = struct{ driver.Driver }{slow}
+			fw.healthCheck["slow"], err = NewHealthCheckInfo("slow", &HealthCheckOptions{
+				Req:      map[string]interface{}{},
+				Interval: 20 * time.Millisecond,
+				Timeout:  100 * time.Millisecond,
+			})
+			So(err, ShouldBeNil)
+			now := time.Now()
+			err = fw.HealthCheck(context.Background())
+			So(time.Since(now), ShouldBeLessThan, 3*time.Second)
+			So(err, ShouldNotBeNil)
+			So(err.(*HealthCheckError).Ctx, ShouldEqual, "slow")
+			So(err.Error(), ShouldContainSubstring, "deadline exceeded")
+		})
+
+		Convey("success references var", func() {
+			fw.var_ = map[string]interface{}{"expect": "ok"}
+			fw.healthCheck["sh"], err = NewHealthCheckInfo("sh", &HealthCheckOptions{
+				Req:      map[string]interface{}{"Command": "echo -n ok"},
+				Success:  "res.Stdout == var.expect",
+				Interval: 20 * time.Millisecond,
+				Timeout:  time.Second,
+			})
+			So(err, ShouldBeNil)
+			So(fw.HealthCheck(context.Background()), ShouldBeNil)
+		})
+
+		Convey("ctx not found", func() {
+			_, err := NewFrameworkWithOptions(&Options{
+				Ctx:         map[string]refx.TypeOptions{"sh": {Type: "Shell", Options: map[string]interface{}{}}},
+				HealthCheck: map[string]HealthCheckOptions{"http": {}},
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMixInfo_Pick(t *testing.T) {
+	Convey("TestMixInfo_Pick", t, func() {
+		units := []*UnitInfo{{Name: "read"}, {Name: "write"}, {Name: "delete"}}
+
+		Convey("normal", func() {
+			mix, err := NewMixInfo("rw", map[string]int{"read": 70, "write": 25, "delete": 5}, units)
+			So(err, ShouldBeNil)
+
+			counts := map[string]int{}
+			for i := 0; i < 100000; i++ {
+				counts[mix.Pick().Name]++
+			}
+			So(counts["read"], ShouldAlmostEqual, 70000, 2000)
+			So(counts["write"], ShouldAlmostEqual, 25000, 2000)
+			So(counts["delete"], ShouldAlmostEqual, 5000, 2000)
+		})
+
+		Convey("non-positive weight", func() {
+			_, err := NewMixInfo("rw", map[string]int{"read": 1, "write": 0}, units)
+			So(err, ShouldNotBeNil)
+			_, err = NewMixInfo("rw", map[string]int{"read": 2, "write": -1}, units)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("unit not found", func() {
+			_, err := NewMixInfo("rw", map[string]int{"update": 1}, units)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("name conflict", func() {
+			_, err := NewMixInfo("read", map[string]int{"read": 1}, units)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("empty weight", func() {
+			_, err := NewMixInfo("rw", map[string]int{}, units)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func BenchmarkFileWriter(b *testing.B) {
 	str := `{"Name":"unit1","Step":[{"Req":{"Command":"echo -n ${KEY1} ${KEY2}","Envs":{"KEY2":"val4","KEY1":"val3"}},"Res":{"Stdout":"val3 val4","Stderr":"","ExitCode":0},"Err":null,"ErrCode":"","ResTime":3716244},{"Req":{"Command":"echo -n ${KEY3} ${KEY4}","Envs":{"KEY3":"val3 val4"}},"Res":{"Stdout":"val3 val4","Stderr":"","ExitCode":0},"Err":null,"ErrCode":"","ResTime":3833361}],"ErrCode":"","ResTime":7631754}`
 	parallel := 20
@@ -0,0 +1,161 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/hatlonely/go-kit/strx"
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/benv2/internal/driver"
+	"github.com/hatlonely/benv2/internal/eval"
+)
+
+type HealthCheckOptions struct {
+	Req interface{}
+	// 判断检查成功的表达式，可以引用 res 和 var，为空时请求没有错误即成功
+	Success string
+	// 检查失败后重试的间隔
+	Interval time.Duration `dft:"1s"`
+	// 等待 ctx 就绪的最长时间，超过后放弃压测
+	Timeout time.Duration `dft:"30s"`
+}
+
+type HealthCheckInfo struct {
+	Ctx      string
+	Req      *eval.Evaluable
+	Success  gval.Evaluable
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+func NewHealthCheckInfo(ctx string, options *HealthCheckOptions) (*HealthCheckInfo, error) {
+	reqEval, err := eval.NewEvaluable(options.Req)
+	if err != nil {
+		return nil, errors.WithMessage(err, "eval.NewEvaluable failed")
+	}
+	var successEval gval.Evaluable
+	if options.Success != "" {
+		successEval, err = eval.Lang.NewEvaluable(options.Success)
+		if err != nil {
+			return nil, errors.Wrap(err, "eval.Lang.NewEvaluable failed")
+		}
+	}
+	info := &HealthCheckInfo{
+		Ctx:      ctx,
+		Req:      reqEval,
+		Success:  successEval,
+		Interval: options.Interval,
+		Timeout:  options.Timeout,
+	}
+	if info.Interval <= 0 {
+		info.Interval = time.Second
+	}
+	if info.Timeout <= 0 {
+		info.Timeout = 30 * time.Second
+	}
+	return info, nil
+}
+
+// HealthCheckError ctx 在等待时间内没有就绪
+type HealthCheckError struct {
+	Ctx     string
+	Timeout time.Duration
+	// 最后一次检查失败的原因
+	Err error
+}
+
+func (e *HealthCheckError) Error() string {
+	return fmt.Sprintf("ctx [%s] is not ready in %v, last err: [%v]", e.Ctx, e.Timeout, e.Err)
+}
+
+// HealthCheck 并发检查所有配置了 HealthCheck 的 ctx，直到全部就绪，有 ctx 超时未就绪时返回 *HealthCheckError
+func (fw *Framework) HealthCheck(ctx context.Context) error {
+	var keys []string
+	for key := range fw.healthCheck {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, info *HealthCheckInfo) {
+			defer wg.Done()
+			errs[i] = fw.waitUntilReady(ctx, info)
+		}(i, fw.healthCheck[key])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fw *Framework) waitUntilReady(ctx context.Context, info *HealthCheckInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, info.Timeout)
+	defer cancel()
+
+	var err error
+	for {
+		// 驱动可能不响应 ctx，在协程中检查，超时后不再等待这次检查返回
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- fw.check(ctx, info)
+		}()
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return &HealthCheckError{Ctx: info.Ctx, Timeout: info.Timeout, Err: err}
+		case err = <-errCh:
+		}
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return &HealthCheckError{Ctx: info.Ctx, Timeout: info.Timeout, Err: err}
+		case <-time.After(info.Interval):
+		}
+	}
+}
+
+func (fw *Framework) check(ctx context.Context, info *HealthCheckInfo) error {
+	req, err := info.Req.Evaluate(map[string]interface{}{"var": fw.var_})
+	if err != nil {
+		return errors.WithMessage(err, "info.Req.Evaluate failed")
+	}
+
+	d := fw.ctx[info.Ctx]
+	var res interface{}
+	if cd, ok := d.(driver.ContextDriver); ok {
+		res, err = cd.DoContext(ctx, req)
+	} else {
+		res, err = d.Do(req)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "driver.Do failed")
+	}
+
+	if info.Success == nil {
+		return nil
+	}
+	success, err := info.Success.EvalBool(context.Background(), map[string]interface{}{"res": res, "var": fw.var_})
+	if err != nil {
+		return errors.Wrap(err, "info.Success.Evaluate failed")
+	}
+	if !success {
+		return errors.Errorf("unexpected res: %s", strx.JsonMarshal(res))
+	}
+	return nil
+}
@@ -16,6 +16,11 @@ type Title struct {
 	AvgResTimeMs        string
 	SuccessRatePercent  string
 	ErrCodeDistribution string
+	Mix                 string
+	ExpectPercent       string
+	ActualPercent       string
+	Endpoint            string
+	TimingMs            string
 	Monitor             string
 }
 
@@ -41,6 +46,11 @@ var defaultI18n = map[string]*I18n{
 			AvgResTimeMs:        "AvgResTimeMs",
 			SuccessRatePercent:  "SuccessRatePercent",
 			ErrCodeDistribution: "ErrCodeDistribution",
+			Mix:                 "Mix",
+			ExpectPercent:       "ExpectPercent",
+			ActualPercent:       "ActualPercent",
+			Endpoint:            "Endpoint",
+			TimingMs:            "TimingMs",
 			Monitor:             "Monitor",
 		},
 		Tooltip: Tooltip{
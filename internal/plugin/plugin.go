@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	goplugin "plugin"
+
+	"github.com/pkg/errors"
+
+	"github.com/hatlonely/benv2/internal/driver"
+)
+
+type Options struct {
+	// Go 或者 Process
+	//
+	// Go 插件为 -buildmode=plugin 编译的 so 文件，可以在 benv2 模块外，但需要和 ben 使用相同版本的 benv2 和依赖编译，
+	// 在 init 中调用 pkg/ben 中的 RegisterDriver，RegisterSource，RegisterRecorder，RegisterReporter 等注册实现
+	//
+	// Process 插件为常驻的子进程，只能注册为名为 Name 的驱动，协议和 CoProcess 驱动一致，
+	// ctx 的 options 为 CoProcessDriverOptions 中的 Scope，PoolSize，Timeout 等，
+	// 数据源，记录器，报告等需要使用 Go 插件
+	Type string `dft:"Go"`
+	// Go 插件 so 文件的路径
+	Path string
+
+	Name    string
+	Command string
+	Args    []string
+	Envs    map[string]string
+	Dir     string
+}
+
+func Load(options *Options) error {
+	switch options.Type {
+	case "", "Go":
+		if options.Path == "" {
+			return errors.New("Path is required")
+		}
+		// 插件的 init 在 Open 时执行，重复打开同一个插件不会重复执行
+		if _, err := goplugin.Open(options.Path); err != nil {
+			return errors.Wrapf(err, "plugin.Open [%s] failed", options.Path)
+		}
+		return nil
+	case "Process":
+		if options.Name == "" || options.Command == "" {
+			return errors.New("Name and Command are required")
+		}
+		driver.RegisterDriver(options.Name, driver.NewWrapDriverWithMethodName(func(o *driver.CoProcessDriverOptions) (*driver.CoProcessDriver, error) {
+			envs := map[string]string{}
+			for k, v := range options.Envs {
+				envs[k] = v
+			}
+			for k, v := range o.Envs {
+				envs[k] = v
+			}
+			o.Command = options.Command
+			o.Args = options.Args
+			o.Envs = envs
+			if o.Dir == "" {
+				o.Dir = options.Dir
+			}
+			return driver.NewCoProcessDriverWithOptions(o)
+		}, "Do"))
+		return nil
+	default:
+		return errors.Errorf("unsupported plugin type [%s]", options.Type)
+	}
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/hatlonely/go-kit/refx"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/hatlonely/benv2/internal/driver"
+	"github.com/hatlonely/benv2/internal/source"
+)
+
+func TestLoad(t *testing.T) {
+	Convey("TestLoad", t, func() {
+		Convey("go", func() {
+			// 编译插件需要以 plugin 模式重新编译所有依赖，比较耗时
+			if testing.Short() {
+				t.Skip("skip building plugin in short mode")
+			}
+			defer os.RemoveAll("echo.so")
+			if out, err := exec.Command("go", "build", "-buildmode=plugin", "-o", "echo.so", "./testdata/echo").CombinedOutput(); err != nil {
+				// 不支持 plugin 的平台或者没有开启 cgo
+				t.Skipf("build plugin failed, err: [%v], output: [%s]", err, out)
+			}
+
+			So(Load(&Options{Path: "echo.so"}), ShouldBeNil)
+			d, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "PluginEcho", Options: map[string]interface{}{"Prefix": "hello"}})
+			So(err, ShouldBeNil)
+			res, err := d.Do("world")
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, map[string]interface{}{"Prefix": "hello", "Req": "world"})
+
+			src, err := source.NewSourceWithOptions(&refx.TypeOptions{Type: "PluginCounter", Options: map[string]interface{}{"Start": 10}})
+			So(err, ShouldBeNil)
+			So(src.Fetch(), ShouldEqual, int64(10))
+			So(src.Fetch(), ShouldEqual, int64(11))
+		})
+
+		Convey("process", func() {
+			So(Load(&Options{
+				Type:    "Process",
+				Name:    "PluginUpper",
+				Command: "bash",
+				Args:    []string{"-c", `while read -r line; do echo "{\"Res\": ${line^^}}"; done`},
+			}), ShouldBeNil)
+			d, err := driver.NewDriverWithOptions(&refx.TypeOptions{Type: "PluginUpper", Options: map[string]interface{}{"Scope": "Pool", "PoolSize": 1}})
+			So(err, ShouldBeNil)
+			res, err := d.Do(map[string]interface{}{"Req": "hello"})
+			So(err, ShouldBeNil)
+			So(res.(map[string]interface{})["Res"], ShouldEqual, "HELLO")
+		})
+
+		Convey("invalid", func() {
+			So(Load(&Options{Path: "not_exists.so"}), ShouldNotBeNil)
+			So(Load(&Options{Type: "Process", Name: "PluginInvalid"}), ShouldNotBeNil)
+			So(Load(&Options{Type: "Wasm"}), ShouldNotBeNil)
+		})
+	})
+}
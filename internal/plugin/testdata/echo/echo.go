@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/hatlonely/benv2/pkg/ben"
+)
+
+func init() {
+	ben.RegisterDriver("PluginEcho", NewEchoDriverWithOptions)
+	ben.RegisterSource("PluginCounter", NewCounterSourceWithOptions)
+}
+
+type EchoDriverOptions struct {
+	Prefix string
+}
+
+func NewEchoDriverWithOptions(options *EchoDriverOptions) (*EchoDriver, error) {
+	return &EchoDriver{options: options}, nil
+}
+
+// EchoDriver 返回带前缀的请求，用于测试插件加载
+type EchoDriver struct {
+	options *EchoDriverOptions
+}
+
+func (d *EchoDriver) Do(req interface{}) (interface{}, error) {
+	return map[string]interface{}{"Prefix": d.options.Prefix, "Req": req}, nil
+}
+
+type CounterSourceOptions struct {
+	Start int64
+}
+
+func NewCounterSourceWithOptions(options *CounterSourceOptions) *CounterSource {
+	return &CounterSource{next: options.Start}
+}
+
+// CounterSource 依次返回递增的整数，用于测试插件注册数据源
+type CounterSource struct {
+	next int64
+}
+
+func (s *CounterSource) Fetch() interface{} {
+	return atomic.AddInt64(&s.next, 1) - 1
+}
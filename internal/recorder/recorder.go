@@ -40,24 +40,48 @@ type Meta struct {
 	Name      string
 	Duration  time.Duration
 	Parallel  []map[string]int
+	Mix       map[string]map[string]int
 	TimeRange []*TimeRange
 }
 
+// ErrCodeAborted 表示请求因为阶段结束被取消，不计入统计
+const ErrCodeAborted = "Aborted"
+
 type UnitStat struct {
 	ID      string
 	Time    string
 	Seq     int
 	Name    string
+	Mix     string
 	Step    []*StepStat
 	ErrCode string
 	ResTime time.Duration
+	Aborted bool
 }
 
 type StepStat struct {
-	Time    string
-	Req     interface{}
-	Res     interface{}
-	Err     string
-	ErrCode string
-	ResTime time.Duration
+	Time      string
+	Req       interface{}
+	Res       interface{}
+	Err       string
+	ErrCode   string
+	ResTime   time.Duration
+	ThinkTime time.Duration
+	// 流式请求的统计，非流式请求为 nil
+	Stream *StreamStat
+	// 请求各个阶段的耗时，如 http 请求的 DNS，Connect，TTFB
+	Timing map[string]time.Duration
+	// 执行 step 的 ctx
+	Ctx string
+	// 处理请求的 endpoint，ctx 为 LoadBalance 驱动时记录
+	Endpoint string
+}
+
+type StreamStat struct {
+	// 从发起请求到收到第一条消息的时间
+	FirstMessageTime time.Duration
+	Duration         time.Duration
+	MessageCount     int
+	// 相邻两条消息的最大间隔
+	MaxMessageGap time.Duration
 }
@@ -27,6 +27,20 @@ type Metric struct {
 	AvgResTimeMs        map[string][]*Measurement
 	SuccessRatePercent  map[string][]*Measurement
 	ErrCodeDistribution map[string]map[string]int
+	// 第一层 map key 为 mix 名，第二层 map key 为 unit 名
+	Mix map[string]map[string]*MixRatio
+	// 流式请求的指标，第一层 map key 为指标名，第二层 map key 为 unit 名
+	Stream map[string]map[string][]*Measurement
+	// 请求各阶段的平均耗时，第一层 map key 为 unit 名，第二层 map key 为阶段名
+	TimingMs map[string]map[string][]*Measurement
+	// 每个 endpoint 处理的请求的汇总，按 step 计数，unit 中多个 step 使用同一个 ctx 时每个 step 都计数
+	// 第一层 map key 为 unit 名，第二层 map key 为 <ctx>/<endpoint>
+	Endpoint map[string]map[string]*Summary
+}
+
+type MixRatio struct {
+	ExpectPercent float64
+	ActualPercent float64
 }
 
 type Measurement struct {
@@ -43,17 +57,23 @@ type Summary struct {
 }
 
 func (s *Statistics) Statistics(id string, analyst Analyst) ([]*Metric, error) {
-	aggregations, err := s.aggregation(id, analyst)
+	meta, err := analyst.Meta()
+	if err != nil {
+		return nil, errors.WithMessage(err, "analyst.Meta failed")
+	}
+
+	aggregations, mixCounts, err := s.aggregation(id, meta, analyst)
 	if err != nil {
 		return nil, errors.WithMessage(err, "aggregation failed")
 	}
 
 	var metrics []*Metric
-	for _, aggregationMap := range aggregations {
+	for i, aggregationMap := range aggregations {
 		metric, err := s.calculate(aggregationMap)
 		if err != nil {
 			return nil, errors.WithMessage(err, "s.calculate failed")
 		}
+		metric.Mix = calculateMix(meta.Mix, mixCounts[i])
 		metrics = append(metrics, metric)
 	}
 
@@ -66,6 +86,9 @@ func (s *Statistics) calculate(aggregationMap map[string][]*Aggregation) (*Metri
 	avgResTimeMsMap := map[string][]*Measurement{}
 	successRatePercentMap := map[string][]*Measurement{}
 	errCodeDistributionMap := map[string]map[string]int{}
+	streamMap := map[string]map[string][]*Measurement{}
+	timingMsMap := map[string]map[string][]*Measurement{}
+	endpointMap := map[string]map[string]*Summary{}
 
 	for key, aggregations := range aggregationMap {
 		summaryMap[key] = calculateSummary(aggregations)
@@ -73,6 +96,31 @@ func (s *Statistics) calculate(aggregationMap map[string][]*Aggregation) (*Metri
 		avgResTimeMsMap[key] = calculateAvgResTimeMs(aggregations)
 		successRatePercentMap[key] = calculateSuccessRatePercent(aggregations)
 		errCodeDistributionMap[key] = calculateErrCodeDistribution(aggregations)
+		for name, measurements := range calculateStream(aggregations) {
+			if _, ok := streamMap[name]; !ok {
+				streamMap[name] = map[string][]*Measurement{}
+			}
+			streamMap[name][key] = measurements
+		}
+	}
+	if len(streamMap) == 0 {
+		streamMap = nil
+	}
+	for key, aggregations := range aggregationMap {
+		if timingMs := calculateTimingMs(aggregations); len(timingMs) != 0 {
+			timingMsMap[key] = timingMs
+		}
+	}
+	if len(timingMsMap) == 0 {
+		timingMsMap = nil
+	}
+	for key, aggregations := range aggregationMap {
+		if endpoint := calculateEndpoint(aggregations); len(endpoint) != 0 {
+			endpointMap[key] = endpoint
+		}
+	}
+	if len(endpointMap) == 0 {
+		endpointMap = nil
 	}
 
 	return &Metric{
@@ -81,11 +129,18 @@ func (s *Statistics) calculate(aggregationMap map[string][]*Aggregation) (*Metri
 		AvgResTimeMs:        avgResTimeMsMap,
 		SuccessRatePercent:  successRatePercentMap,
 		ErrCodeDistribution: errCodeDistributionMap,
+		Stream:              streamMap,
+		TimingMs:            timingMsMap,
+		Endpoint:            endpointMap,
 	}, nil
 }
 
+// calculateSummary 汇总 unit 的数量，QPS，平均响应时间和成功率，没有数据的指标为 0
 func calculateSummary(aggregations []*Aggregation) *Summary {
 	var summary Summary
+	if len(aggregations) == 0 {
+		return &summary
+	}
 	totalResTime := time.Duration(0)
 	// 丢弃最后一次结果
 	for i := 0; i < len(aggregations)-1; i++ {
@@ -93,9 +148,15 @@ func calculateSummary(aggregations []*Aggregation) *Summary {
 		summary.Pass += aggregations[i].Pass
 		totalResTime += aggregations[i].PassResTime
 	}
-	summary.QPS = float64(summary.Pass) / aggregations[len(aggregations)-1].Time.Sub(aggregations[0].Time).Seconds()
-	summary.AvgResTimeMs = float64(totalResTime.Milliseconds()) / float64(summary.Pass)
-	summary.SuccessRatePercent = float64(summary.Pass*100) / float64(summary.Total)
+	if seconds := aggregations[len(aggregations)-1].Time.Sub(aggregations[0].Time).Seconds(); seconds > 0 {
+		summary.QPS = float64(summary.Pass) / seconds
+	}
+	if summary.Pass != 0 {
+		summary.AvgResTimeMs = float64(totalResTime.Milliseconds()) / float64(summary.Pass)
+	}
+	if summary.Total != 0 {
+		summary.SuccessRatePercent = float64(summary.Pass*100) / float64(summary.Total)
+	}
 	return &summary
 }
 
@@ -138,6 +199,127 @@ func calculateSuccessRatePercent(aggregations []*Aggregation) []*Measurement {
 	return successRatePercent
 }
 
+// calculateStream 计算流式请求的平均首条消息时间，平均流持续时间，平均消息数和平均最大消息间隔，没有流式请求时返回 nil
+func calculateStream(aggregations []*Aggregation) map[string][]*Measurement {
+	var firstMessageTimeMs, durationMs, messageCount, maxMessageGapMs []*Measurement
+	for _, aggregation := range aggregations {
+		if aggregation.Stream == 0 {
+			continue
+		}
+		firstMessageTimeMs = append(firstMessageTimeMs, &Measurement{
+			Time:  aggregation.Time,
+			Value: float64(aggregation.StreamFirstMessageTime.Milliseconds()) / float64(aggregation.Stream),
+		})
+		durationMs = append(durationMs, &Measurement{
+			Time:  aggregation.Time,
+			Value: float64(aggregation.StreamDuration.Milliseconds()) / float64(aggregation.Stream),
+		})
+		messageCount = append(messageCount, &Measurement{
+			Time:  aggregation.Time,
+			Value: float64(aggregation.StreamMessageCount) / float64(aggregation.Stream),
+		})
+		maxMessageGapMs = append(maxMessageGapMs, &Measurement{
+			Time:  aggregation.Time,
+			Value: float64(aggregation.StreamMaxMessageGap.Milliseconds()) / float64(aggregation.Stream),
+		})
+	}
+	if len(firstMessageTimeMs) == 0 {
+		return nil
+	}
+
+	return map[string][]*Measurement{
+		"AvgFirstMessageTimeMs": firstMessageTimeMs,
+		"AvgStreamDurationMs":   durationMs,
+		"AvgMessageCount":       messageCount,
+		"AvgMaxMessageGapMs":    maxMessageGapMs,
+	}
+}
+
+// calculateTimingMs 计算每个阶段的平均耗时，unit 中多个 step 的同一阶段耗时累加
+func calculateTimingMs(aggregations []*Aggregation) map[string][]*Measurement {
+	timingMs := map[string][]*Measurement{}
+	for _, aggregation := range aggregations {
+		if aggregation.TimingUnit == 0 {
+			continue
+		}
+		for phase, duration := range aggregation.Timing {
+			timingMs[phase] = append(timingMs[phase], &Measurement{
+				Time:  aggregation.Time,
+				Value: float64(duration.Microseconds()) / 1000 / float64(aggregation.TimingUnit),
+			})
+		}
+	}
+	return timingMs
+}
+
+// calculateEndpoint 按 endpoint 汇总 step 的数量，QPS，平均响应时间和成功率，和 calculateSummary 一样丢弃最后一次结果
+func calculateEndpoint(aggregations []*Aggregation) map[string]*Summary {
+	endpoint := map[string]*Summary{}
+	if len(aggregations) == 0 {
+		return endpoint
+	}
+	totalResTime := map[string]time.Duration{}
+	for i := 0; i < len(aggregations)-1; i++ {
+		for name, e := range aggregations[i].Endpoint {
+			if _, ok := endpoint[name]; !ok {
+				endpoint[name] = &Summary{}
+			}
+			endpoint[name].Total += e.Total
+			endpoint[name].Pass += e.Pass
+			totalResTime[name] += e.PassResTime
+		}
+	}
+	seconds := aggregations[len(aggregations)-1].Time.Sub(aggregations[0].Time).Seconds()
+	for name, summary := range endpoint {
+		if seconds > 0 {
+			summary.QPS = float64(summary.Pass) / seconds
+		}
+		if summary.Pass != 0 {
+			summary.AvgResTimeMs = float64(totalResTime[name].Milliseconds()) / float64(summary.Pass)
+		}
+		if summary.Total != 0 {
+			summary.SuccessRatePercent = float64(summary.Pass*100) / float64(summary.Total)
+		}
+	}
+	return endpoint
+}
+
+// calculateMix 计算每个 mix 中各 unit 期望的占比和实际的占比
+func calculateMix(mixWeight map[string]map[string]int, mixCount map[string]map[string]int) map[string]map[string]*MixRatio {
+	if len(mixCount) == 0 {
+		return nil
+	}
+
+	mixRatioMap := map[string]map[string]*MixRatio{}
+	for mix, counts := range mixCount {
+		totalWeight := 0
+		for _, weight := range mixWeight[mix] {
+			totalWeight += weight
+		}
+		totalCount := 0
+		for _, count := range counts {
+			totalCount += count
+		}
+
+		ratioMap := map[string]*MixRatio{}
+		for unit, weight := range mixWeight[mix] {
+			ratioMap[unit] = &MixRatio{}
+			if totalWeight != 0 {
+				ratioMap[unit].ExpectPercent = float64(weight*100) / float64(totalWeight)
+			}
+		}
+		for unit, count := range counts {
+			if _, ok := ratioMap[unit]; !ok {
+				ratioMap[unit] = &MixRatio{}
+			}
+			ratioMap[unit].ActualPercent = float64(count*100) / float64(totalCount)
+		}
+		mixRatioMap[mix] = ratioMap
+	}
+
+	return mixRatioMap
+}
+
 func calculateErrCodeDistribution(aggregations []*Aggregation) map[string]int {
 	errCodeDistribution := map[string]int{}
 	for _, aggregation := range aggregations {
@@ -157,44 +339,81 @@ type Aggregation struct {
 	PassResTime  time.Duration
 	Fail         int
 	ErrCode      map[string]int
+	// 流式请求的数量及其累计的统计
+	Stream                 int
+	StreamFirstMessageTime time.Duration
+	StreamDuration         time.Duration
+	StreamMessageCount     int
+	StreamMaxMessageGap    time.Duration
+	// 有阶段耗时的 unit 的数量及各阶段累计的耗时
+	TimingUnit int
+	Timing     map[string]time.Duration
+	// 每个 endpoint 处理的 step 的统计，key 为 <ctx>/<endpoint>
+	Endpoint map[string]*EndpointAggregation
 }
 
-func (s *Statistics) aggregation(id string, analyst Analyst) ([]map[string][]*Aggregation, error) {
-	meta, err := analyst.Meta()
-	if err != nil {
-		return nil, errors.WithMessage(err, "analyst.TimeRange")
-	}
+type EndpointAggregation struct {
+	Total       int
+	Pass        int
+	PassResTime time.Duration
+}
 
-	interval := s.options.Interval
-	if s.options.Interval == 0 {
-		if s.options.PointNumber == 0 {
-			s.options.PointNumber = 100
+func (s *Statistics) aggregation(id string, meta *Meta, analyst Analyst) ([]map[string][]*Aggregation, []map[string]map[string]int, error) {
+	if s.options.PointNumber == 0 {
+		s.options.PointNumber = 100
+	}
+	// 阶段可能提前结束，按照每个阶段实际的时间范围计算间隔
+	intervals := make([]time.Duration, len(meta.TimeRange))
+	for i, timeRange := range meta.TimeRange {
+		intervals[i] = s.options.Interval
+		if s.options.Interval == 0 {
+			intervals[i] = timeRange.EndTime.Sub(timeRange.StartTime) / time.Duration(s.options.PointNumber)
+		}
+		if intervals[i] < time.Millisecond {
+			intervals[i] = time.Millisecond
 		}
-		interval = meta.Duration / time.Duration(s.options.PointNumber)
 	}
 
 	aggregationIdxMap := map[int]map[string][]*Aggregation{}
+	mixCountIdxMap := map[int]map[string]map[string]int{}
 
 	stream, err := analyst.UnitStatStream(id)
 	if err != nil {
-		return nil, errors.WithMessage(err, "analyst.UnitStatStream failed")
+		return nil, nil, errors.WithMessage(err, "analyst.UnitStatStream failed")
 	}
 	for {
 		stat, err := stream.Next()
 		if err != nil {
-			return nil, errors.WithMessage(err, "stream.Next failed")
+			return nil, nil, errors.WithMessage(err, "stream.Next failed")
 		}
 
 		if stat == nil {
 			break
 		}
 
+		if stat.Aborted {
+			continue
+		}
+
 		t, err := time.Parse(time.RFC3339Nano, stat.Time)
 		if err != nil {
-			return nil, errors.WithMessage(err, "time.Parse failed")
+			return nil, nil, errors.WithMessage(err, "time.Parse failed")
+		}
+
+		if stat.Mix != "" {
+			mixCountMap, ok := mixCountIdxMap[stat.Seq]
+			if !ok {
+				mixCountMap = map[string]map[string]int{}
+				mixCountIdxMap[stat.Seq] = mixCountMap
+			}
+			if _, ok := mixCountMap[stat.Mix]; !ok {
+				mixCountMap[stat.Mix] = map[string]int{}
+			}
+			mixCountMap[stat.Mix][stat.Name] += 1
 		}
 
 		timeRange := meta.TimeRange[stat.Seq]
+		interval := intervals[stat.Seq]
 		idx := int(t.Sub(timeRange.StartTime) / interval)
 
 		aggregationMap, ok := aggregationIdxMap[stat.Seq]
@@ -209,6 +428,8 @@ func (s *Statistics) aggregation(id string, analyst Analyst) ([]map[string][]*Ag
 					Time:     i,
 					Duration: interval,
 					ErrCode:  map[string]int{},
+					Timing:   map[string]time.Duration{},
+					Endpoint: map[string]*EndpointAggregation{},
 				})
 			}
 			aggregationMap[stat.Name] = aggregations
@@ -228,12 +449,51 @@ func (s *Statistics) aggregation(id string, analyst Analyst) ([]map[string][]*Ag
 			aggregation.PassResTime += stat.ResTime
 			aggregation.ErrCode["OK"] += 1
 		}
+		hasTiming := false
+		for _, step := range stat.Step {
+			for phase, duration := range step.Timing {
+				aggregation.Timing[phase] += duration
+				hasTiming = true
+			}
+			if step.Endpoint != "" {
+				// 不同的 LoadBalance ctx 的 endpoint 可能同名，如默认的序号
+				key := step.Ctx + "/" + step.Endpoint
+				endpoint, ok := aggregation.Endpoint[key]
+				if !ok {
+					endpoint = &EndpointAggregation{}
+					aggregation.Endpoint[key] = endpoint
+				}
+				endpoint.Total += 1
+				if step.ErrCode == "" {
+					endpoint.Pass += 1
+					endpoint.PassResTime += step.ResTime
+				}
+			}
+			if step.Stream == nil {
+				continue
+			}
+			aggregation.Stream += 1
+			aggregation.StreamFirstMessageTime += step.Stream.FirstMessageTime
+			aggregation.StreamDuration += step.Stream.Duration
+			aggregation.StreamMessageCount += step.Stream.MessageCount
+			aggregation.StreamMaxMessageGap += step.Stream.MaxMessageGap
+		}
+		if hasTiming {
+			aggregation.TimingUnit += 1
+		}
 	}
 
+	// 每个阶段对应一个结果，和 meta.TimeRange 对齐，没有记录的阶段，如数据源已耗尽或者请求都被中断，结果为空
 	var aggregations []map[string][]*Aggregation
-	for i := 0; i < len(aggregationIdxMap); i++ {
-		aggregations = append(aggregations, aggregationIdxMap[i])
+	var mixCounts []map[string]map[string]int
+	for i := range meta.TimeRange {
+		aggregationMap, ok := aggregationIdxMap[i]
+		if !ok {
+			aggregationMap = map[string][]*Aggregation{}
+		}
+		aggregations = append(aggregations, aggregationMap)
+		mixCounts = append(mixCounts, mixCountIdxMap[i])
 	}
 
-	return aggregations, nil
+	return aggregations, mixCounts, nil
 }
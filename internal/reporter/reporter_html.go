@@ -318,6 +318,60 @@ var unitTplStr = `
     </div>
 </div>
 
+{{ range $mix, $mixRatio := $.Metric.Mix }}
+<div class="col-md-12">
+	<div class="card-body d-flex justify-content-center">
+		<table class="table table-striped">
+			<thead>
+				<tr class="text-center">
+					<th>{{ $.I18n.Title.Mix }}({{ $mix }})</th>
+					<th>{{ $.I18n.Title.ExpectPercent }}</th>
+					<th>{{ $.I18n.Title.ActualPercent }}</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range $key, $ratio := $mixRatio }}
+				<tr class="text-center">
+					<th>{{ $key }}</th>
+					<td>{{ FormatFloat $ratio.ExpectPercent }}</td>
+					<td>{{ FormatFloat $ratio.ActualPercent }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+	</div>
+</div>
+{{ end }}
+
+{{ range $unit, $endpoint := $.Metric.Endpoint }}
+<div class="col-md-12">
+	<div class="card-body d-flex justify-content-center">
+		<table class="table table-striped">
+			<thead>
+				<tr class="text-center">
+					<th>{{ $.I18n.Title.Endpoint }}({{ $unit }})</th>
+					<th>{{ $.I18n.Title.Total }}</th>
+					<th>{{ $.I18n.Title.QPS }}</th>
+					<th>{{ $.I18n.Title.AvgResTimeMs }}</th>
+					<th>{{ $.I18n.Title.SuccessRatePercent }}</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range $key, $summary := $endpoint }}
+				<tr class="text-center">
+					<th>{{ $key }}</th>
+					<td>{{ $summary.Total }}</td>
+					<td>{{ FormatFloat $summary.QPS }}</td>
+					<td>{{ FormatFloat $summary.AvgResTimeMs }}</td>
+					<td>{{ FormatFloat $summary.SuccessRatePercent }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+	</div>
+</div>
+{{ end }}
+
 <div class="col-md-12">
 	<div class="card-body d-flex justify-content-center">
         <div class="col-md-12" id="{{ printf "%s-unit-%d-qps" $.Meta.Name $.Idx }}" style="height: 300px;"></div>
@@ -465,6 +519,109 @@ var unitTplStr = `
     </div>
 </div>
 
+{{ range $graph, $stream := $.Metric.Stream }}
+<div class="col-md-12">
+	<div class="card-body d-flex justify-content-center">
+        <div class="col-md-12" id="{{ printf "%s-unit-%d-stream-%s" $.Meta.Name $.Idx $graph }}" style="height: 300px;"></div>
+        <script>
+            echarts.init(document.getElementById("{{ printf "%s-unit-%d-stream-%s" $.Meta.Name $.Idx $graph }}")).setOption({
+              title: {
+                text: "{{ $graph }}",
+                left: "center",
+              },
+              textStyle: {
+                fontFamily: "{{ $.Customize.Font.Echarts }}",
+              },
+              tooltip: {
+                trigger: 'axis',
+                show: true,
+                axisPointer: {
+                    type: "cross"
+                }
+              },
+              toolbox: {
+                feature: {
+                  saveAsImage: {
+                    title: "{{ $.I18n.Tooltip.Save }}"
+                  }
+                }
+              },
+              xAxis: {
+                type: "time",
+              },
+              yAxis: {
+                type: "value",
+              },
+              series: [
+                {{ range $key, $measurement := $stream }}
+                {
+                  name: "{{ $key }}",
+                  type: "line",
+                  smooth: true,
+                  symbol: "none",
+                  areaStyle: {},
+                  data: {{ JsonMarshal (MeasurementToSerial $measurement) }}
+                },
+                {{ end }}
+              ]
+            });
+        </script>
+    </div>
+</div>
+{{ end }}
+
+{{ range $unit, $timing := $.Metric.TimingMs }}
+<div class="col-md-12">
+	<div class="card-body d-flex justify-content-center">
+        <div class="col-md-12" id="{{ printf "%s-unit-%d-timing-%s" $.Meta.Name $.Idx $unit }}" style="height: 300px;"></div>
+        <script>
+            echarts.init(document.getElementById("{{ printf "%s-unit-%d-timing-%s" $.Meta.Name $.Idx $unit }}")).setOption({
+              title: {
+                text: "{{ $unit }} {{ $.I18n.Title.TimingMs }}",
+                left: "center",
+              },
+              textStyle: {
+                fontFamily: "{{ $.Customize.Font.Echarts }}",
+              },
+              tooltip: {
+                trigger: 'axis',
+                show: true,
+                axisPointer: {
+                    type: "cross"
+                }
+              },
+              toolbox: {
+                feature: {
+                  saveAsImage: {
+                    title: "{{ $.I18n.Tooltip.Save }}"
+                  }
+                }
+              },
+              xAxis: {
+                type: "time",
+              },
+              yAxis: {
+                type: "value",
+              },
+              series: [
+                {{ range $phase, $measurement := $timing }}
+                {
+                  name: "{{ $phase }}",
+                  type: "line",
+                  stack: "timing",
+                  smooth: true,
+                  symbol: "none",
+                  areaStyle: {},
+                  data: {{ JsonMarshal (MeasurementToSerial $measurement) }}
+                },
+                {{ end }}
+              ]
+            });
+        </script>
+    </div>
+</div>
+{{ end }}
+
 <div class="card-header justify-content-between d-flex">{{ .I18n.Title.Monitor }}</div>
 {{ range $graph, $monitor := $.Monitor }}
 <div class="col-md-12">
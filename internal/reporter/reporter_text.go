@@ -44,11 +44,27 @@ func (r *TextReporter) buildUnit(parallel map[string]int, metric *recorder.Metri
 	buf.WriteString(buildParallel(parallel))
 	buf.WriteByte('\n')
 
-	buf.WriteString(buildSummary(r.options.TitleWidth, metric.Summary))
+	buf.WriteString(buildSummary(r.options.TitleWidth, "summary", metric.Summary))
 	buf.WriteByte('\n')
 
 	buf.WriteString(buildErrCodeDistribution(metric.ErrCodeDistribution))
 	buf.WriteByte('\n')
+
+	if len(metric.Mix) != 0 {
+		buf.WriteString(buildMix(r.options.TitleWidth, metric.Mix))
+		buf.WriteByte('\n')
+	}
+
+	var endpointUnits []string
+	for key := range metric.Endpoint {
+		endpointUnits = append(endpointUnits, key)
+	}
+	sort.Strings(endpointUnits)
+	for _, key := range endpointUnits {
+		buf.WriteString(buildSummary(r.options.TitleWidth, key+" steps by endpoint", metric.Endpoint[key]))
+		buf.WriteByte('\n')
+	}
+
 	buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, "QPS", metric.QPS))
 	buf.WriteByte('\n')
 	buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, "AvgResTimeMs", metric.AvgResTimeMs))
@@ -56,6 +72,26 @@ func (r *TextReporter) buildUnit(parallel map[string]int, metric *recorder.Metri
 	buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, "SuccessRatePercent", metric.SuccessRatePercent))
 	buf.WriteByte('\n')
 
+	var streams []string
+	for key := range metric.Stream {
+		streams = append(streams, key)
+	}
+	sort.Strings(streams)
+	for _, key := range streams {
+		buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, key, metric.Stream[key]))
+		buf.WriteByte('\n')
+	}
+
+	var units []string
+	for key := range metric.TimingMs {
+		units = append(units, key)
+	}
+	sort.Strings(units)
+	for _, key := range units {
+		buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, key+" TimingMs", metric.TimingMs[key]))
+		buf.WriteByte('\n')
+	}
+
 	for key, val := range monitor_ {
 		buf.WriteString(buildMeasurementMap(r.options.TitleWidth, r.options.ValueWidth, key, val))
 		buf.WriteByte('\n')
@@ -79,12 +115,12 @@ func buildParallel(parallel map[string]int) string {
 	return strings.Join(parallels, ", ") + "\n"
 }
 
-func buildSummary(titleWidth int, summaryMap map[string]*recorder.Summary) string {
+func buildSummary(titleWidth int, header string, summaryMap map[string]*recorder.Summary) string {
 	var buf bytes.Buffer
 
 	width := titleWidth
-	if width < len("summary") {
-		width = len("summary")
+	if width < len(header) {
+		width = len(header)
 	}
 	var keys []string
 	for key := range summaryMap {
@@ -102,7 +138,7 @@ func buildSummary(titleWidth int, summaryMap map[string]*recorder.Summary) strin
 		"SuccessRatePercent",
 	}
 	buf.WriteByte('|')
-	appendCenter(&buf, width, "summary")
+	appendCenter(&buf, width, header)
 	buf.WriteByte('|')
 	for _, title := range titles {
 		appendCenter(&buf, len(title)+2, title)
@@ -153,6 +189,69 @@ func buildErrCodeDistribution(errCodeDistribution map[string]map[string]int) str
 	return buf.String()
 }
 
+func buildMix(titleWidth int, mixMap map[string]map[string]*recorder.MixRatio) string {
+	var buf bytes.Buffer
+
+	var mixes []string
+	for mix := range mixMap {
+		mixes = append(mixes, mix)
+	}
+	sort.Strings(mixes)
+
+	titles := []string{
+		"ExpectPercent",
+		"ActualPercent",
+	}
+	for _, mix := range mixes {
+		width := titleWidth
+		if width < len(mix) {
+			width = len(mix)
+		}
+		var keys []string
+		for key := range mixMap[mix] {
+			keys = append(keys, key)
+			if len(key) > width {
+				width = len(key)
+			}
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('|')
+		appendCenter(&buf, width, mix)
+		buf.WriteByte('|')
+		for _, title := range titles {
+			appendCenter(&buf, len(title)+2, title)
+			buf.WriteByte('|')
+		}
+		buf.WriteByte('\n')
+		buf.WriteByte('|')
+		for i := 0; i < width; i++ {
+			buf.WriteByte('-')
+		}
+		buf.WriteByte('|')
+		for _, title := range titles {
+			for i := 0; i < len(title)+2; i++ {
+				buf.WriteByte('-')
+			}
+			buf.WriteByte('|')
+		}
+		buf.WriteByte('\n')
+
+		for _, key := range keys {
+			buf.WriteByte('|')
+			appendCenter(&buf, width, key)
+			buf.WriteByte('|')
+			appendCenter(&buf, len(titles[0])+2, fmt.Sprintf("%.2f", mixMap[mix][key].ExpectPercent))
+			buf.WriteByte('|')
+			appendCenter(&buf, len(titles[1])+2, fmt.Sprintf("%.2f", mixMap[mix][key].ActualPercent))
+			buf.WriteByte('|')
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.String()
+}
+
 func appendCenter(buf *bytes.Buffer, width int, str string) {
 	if len(str) >= width {
 		buf.WriteString(str)
@@ -26,3 +26,13 @@ func NewSourceWithOptions(options *refx.TypeOptions, opts ...refx.Option) (Sourc
 type Source interface {
 	Fetch() interface{}
 }
+
+var ErrExhausted = errors.New("source exhausted")
+
+// FiniteSource 是有限的数据源，IsFinite 为 true 时数据耗尽后 Next 返回 ErrExhausted
+type FiniteSource interface {
+	Source
+	Next() (interface{}, error)
+	// 循环读取时数据源不会耗尽，返回 false
+	IsFinite() bool
+}
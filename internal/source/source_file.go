@@ -2,6 +2,7 @@ package source
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"os"
 	"sync/atomic"
@@ -13,6 +14,8 @@ import (
 type FileSourceOptions struct {
 	FilePath         string
 	IgnoreParseError bool
+	// 每条记录在整个计划中只读取一次，读取完毕后数据源耗尽，之后的阶段会立即结束
+	Once bool
 }
 
 func NewFileSourceWithOptions(options *FileSourceOptions) (*FileSource, error) {
@@ -20,6 +23,7 @@ func NewFileSourceWithOptions(options *FileSourceOptions) (*FileSource, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "os.Open [%s] failed", options.FilePath)
 	}
+	defer fp.Close()
 
 	var source []interface{}
 	reader := bufio.NewReader(fp)
@@ -28,7 +32,10 @@ func NewFileSourceWithOptions(options *FileSourceOptions) (*FileSource, error) {
 		if err != nil && err != io.EOF {
 			return nil, errors.Wrapf(err, "reader.ReadString failed")
 		}
-		if len(buf) == 1 {
+		if len(bytes.TrimSpace(buf)) == 0 {
+			if err == io.EOF {
+				break
+			}
 			continue
 		}
 		var v interface{}
@@ -46,9 +53,15 @@ func NewFileSourceWithOptions(options *FileSourceOptions) (*FileSource, error) {
 		}
 	}
 
+	// 循环读取时没有数据无法返回记录
+	if len(source) == 0 && !options.Once {
+		return nil, errors.Errorf("no record in file [%s]", options.FilePath)
+	}
+
 	return &FileSource{
 		source: source,
 		len:    uint64(len(source)),
+		once:   options.Once,
 	}, nil
 }
 
@@ -56,11 +69,28 @@ type FileSource struct {
 	source []interface{}
 	idx    uint64
 	len    uint64
+	once   bool
 }
 
 func (s *FileSource) Fetch() interface{} {
+	v, _ := s.Next()
+	return v
+}
+
+func (s *FileSource) IsFinite() bool {
+	return s.once
+}
+
+func (s *FileSource) Next() (interface{}, error) {
 	idx := atomic.AddUint64(&s.idx, 1)
+	if s.once {
+		if idx > s.len {
+			return nil, ErrExhausted
+		}
+		return s.source[idx-1], nil
+	}
+
 	idx %= s.len
 
-	return s.source[idx]
+	return s.source[idx], nil
 }
@@ -0,0 +1,66 @@
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileSource(t *testing.T) {
+	testFile := "test.source.json"
+	_ = ioutil.WriteFile(testFile, []byte(`{"key1": "val1"}
+{"key1": "val2"}
+{"key1": "val3"}
+`), 0644)
+	defer os.RemoveAll(testFile)
+
+	Convey("TestFileSource", t, func() {
+		Convey("loop", func() {
+			src, err := NewFileSourceWithOptions(&FileSourceOptions{
+				FilePath: testFile,
+			})
+			So(err, ShouldBeNil)
+			So(src.len, ShouldEqual, 3)
+			So(src.IsFinite(), ShouldBeFalse)
+			for i := 0; i < 10; i++ {
+				v, err := src.Next()
+				So(err, ShouldBeNil)
+				So(v, ShouldNotBeNil)
+			}
+		})
+
+		Convey("once", func() {
+			src, err := NewFileSourceWithOptions(&FileSourceOptions{
+				FilePath: testFile,
+				Once:     true,
+			})
+			So(err, ShouldBeNil)
+			So(src.IsFinite(), ShouldBeTrue)
+			for _, val := range []string{"val1", "val2", "val3"} {
+				v, err := src.Next()
+				So(err, ShouldBeNil)
+				So(v, ShouldResemble, map[string]interface{}{"key1": val})
+			}
+			v, err := src.Next()
+			So(err, ShouldEqual, ErrExhausted)
+			So(v, ShouldBeNil)
+			So(src.Fetch(), ShouldBeNil)
+		})
+
+		Convey("empty file", func() {
+			emptyFile := "test.empty.json"
+			So(ioutil.WriteFile(emptyFile, []byte("\n"), 0644), ShouldBeNil)
+			defer os.RemoveAll(emptyFile)
+
+			_, err := NewFileSourceWithOptions(&FileSourceOptions{FilePath: emptyFile})
+			So(err, ShouldNotBeNil)
+
+			src, err := NewFileSourceWithOptions(&FileSourceOptions{FilePath: emptyFile, Once: true})
+			So(err, ShouldBeNil)
+			_, err = src.Next()
+			So(err, ShouldEqual, ErrExhausted)
+		})
+	})
+}
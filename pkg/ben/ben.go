@@ -0,0 +1,99 @@
+// Package ben 导出插件需要的注册函数和接口
+//
+// internal 下的包只能在 benv2 模块内引用，模块外的 Go 插件通过这个包注册驱动，数据源，记录器，报告等实现
+package ben
+
+import (
+	"context"
+
+	"github.com/hatlonely/benv2/internal/driver"
+	"github.com/hatlonely/benv2/internal/monitor"
+	"github.com/hatlonely/benv2/internal/recorder"
+	"github.com/hatlonely/benv2/internal/reporter"
+	"github.com/hatlonely/benv2/internal/source"
+)
+
+type (
+	Driver        = driver.Driver
+	ContextDriver = driver.ContextDriver
+	Error         = driver.Error
+	Middleware    = driver.Middleware
+	Handler       = driver.Handler
+	HttpSigner    = driver.HttpSigner
+	Session       = driver.Session
+	Trace         = driver.Trace
+
+	Source       = source.Source
+	FiniteSource = source.FiniteSource
+
+	Recorder   = recorder.Recorder
+	Analyst    = recorder.Analyst
+	StatStream = recorder.StatStream
+	Meta       = recorder.Meta
+	UnitStat   = recorder.UnitStat
+	StepStat   = recorder.StepStat
+	StreamStat = recorder.StreamStat
+
+	Reporter = reporter.Reporter
+	Monitor  = monitor.Monitor
+)
+
+// ErrExhausted 有限的数据源耗尽后 Next 返回的错误
+var ErrExhausted = source.ErrExhausted
+
+const ErrCodeTimeout = driver.ErrCodeTimeout
+
+func RegisterDriver(key string, constructor interface{}) {
+	driver.RegisterDriver(key, constructor)
+}
+
+func RegisterMiddleware(key string, constructor interface{}) {
+	driver.RegisterMiddleware(key, constructor)
+}
+
+func RegisterHttpSigner(key string, constructor interface{}) {
+	driver.RegisterHttpSigner(key, constructor)
+}
+
+func RegisterSource(key string, constructor interface{}) {
+	source.RegisterSource(key, constructor)
+}
+
+func RegisterRecorder(key string, constructor interface{}) {
+	recorder.RegisterRecorder(key, constructor)
+}
+
+func RegisterAnalyst(key string, constructor interface{}) {
+	recorder.RegisterAnalyst(key, constructor)
+}
+
+func RegisterReporter(key string, constructor interface{}) {
+	reporter.RegisterReporter(key, constructor)
+}
+
+func RegisterMonitor(key string, constructor interface{}) {
+	monitor.RegisterMonitor(key, constructor)
+}
+
+func NewError(err error, code string, message string) *Error {
+	return driver.NewError(err, code, message)
+}
+
+func NewErrorf(err error, code string, format string, v ...interface{}) *Error {
+	return driver.NewErrorf(err, code, format, v...)
+}
+
+// TraceFromContext 返回 ctx 中的追踪信息，不在框架中执行时返回 nil
+func TraceFromContext(ctx context.Context) *Trace {
+	return driver.TraceFromContext(ctx)
+}
+
+// UnitSession 返回当前 unit 的 Session，不在框架中执行时返回 nil
+func UnitSession(ctx context.Context) *Session {
+	return driver.UnitSession(ctx)
+}
+
+// VUSession 返回当前 VU 的 Session，不在框架中执行时返回 nil
+func VUSession(ctx context.Context) *Session {
+	return driver.VUSession(ctx)
+}